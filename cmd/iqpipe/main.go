@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/binary"
+	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 
@@ -19,6 +21,12 @@ var (
 	powerFFTs   int
 	imageWidth  int
 	pcmHz       uint
+	heatmapCfg  nicerx.HeatmapConfig
+	colormap    string
+	bfoHz       float64
+	toneHz      float64
+	lsb         bool
+	syncAM      bool
 )
 
 var rootCmd = &cobra.Command{
@@ -59,6 +67,19 @@ func init() {
 	addFlagBand(spectrogramCmd)
 	rootCmd.AddCommand(spectrogramCmd)
 
+	heatmapCmd := &cobra.Command{
+		Use:   "heatmap [flags] sweep.csv output.png",
+		Short: "Write time vs frequency heatmap of an rtl_power csv",
+		Args:  cobra.ExactArgs(2),
+		Run:   func(cmd *cobra.Command, args []string) { heatmap(args[0], args[1]) },
+	}
+	heatmapCmd.Flags().Float64VarP(&heatmapCfg.MinDB, "min-db", "", 0, "Power at the bottom of the color scale")
+	heatmapCmd.Flags().Float64VarP(&heatmapCfg.MaxDB, "max-db", "", 0, "Power at the top of the color scale; defaults to the data's range")
+	heatmapCmd.Flags().StringVarP(&colormap, "colormap", "", "default", "default, gray, viridis, or inferno")
+	heatmapCmd.Flags().IntVarP(&heatmapCfg.Width, "image-width", "w", 0, "Width of plot; defaults to a pixel per bin")
+	heatmapCmd.Flags().IntVarP(&heatmapCfg.RowHeight, "row-height", "r", 1, "Height of each sweep in pixels")
+	rootCmd.AddCommand(heatmapCmd)
+
 	demodCmd := &cobra.Command{
 		Use:   "fmdemod iqfile pcmfile",
 		Short: "FM demodulate an iq8 file to PCM",
@@ -68,6 +89,47 @@ func init() {
 	demodCmd.Flags().UintVarP(&pcmHz, "pcm-rate", "p", 0, "PCM sampling rate in Hz")
 	addFlagBand(demodCmd)
 	rootCmd.AddCommand(demodCmd)
+
+	amCmd := &cobra.Command{
+		Use:   "amdemod iqfile pcmfile",
+		Short: "AM demodulate an iq8 file to PCM",
+		Run: func(cmd *cobra.Command, args []string) {
+			f := dsp.DemodAM
+			if syncAM {
+				f = dsp.DemodSyncAM
+			}
+			audioDemod(f, args[0], args[1])
+		},
+	}
+	amCmd.Flags().BoolVarP(&syncAM, "sync", "", false, "Lock to the carrier instead of detecting the envelope")
+	addFlagAudio(amCmd, dsp.DefaultAMBandwidthHz)
+	rootCmd.AddCommand(amCmd)
+
+	ssbCmd := &cobra.Command{
+		Use:   "ssbdemod iqfile pcmfile",
+		Short: "SSB demodulate an iq8 file to PCM",
+		Run:   func(cmd *cobra.Command, args []string) { audioDemod(dsp.DemodSSB, args[0], args[1]) },
+	}
+	ssbCmd.Flags().BoolVarP(&lsb, "lsb", "", false, "Lower sideband instead of upper")
+	addFlagAudio(ssbCmd, dsp.DefaultSSBBandwidthHz)
+	rootCmd.AddCommand(ssbCmd)
+
+	cwCmd := &cobra.Command{
+		Use:   "cwdemod iqfile pcmfile",
+		Short: "CW demodulate an iq8 file to PCM",
+		Run:   func(cmd *cobra.Command, args []string) { audioDemod(dsp.DemodCW, args[0], args[1]) },
+	}
+	cwCmd.Flags().Float64VarP(&toneHz, "tone", "t", dsp.DefaultCWToneHz, "Pitch of the signal in Hz")
+	addFlagAudio(cwCmd, dsp.DefaultCWBandwidthHz)
+	rootCmd.AddCommand(cwCmd)
+}
+
+func addFlagAudio(cmd *cobra.Command, bwHz float64) {
+	cmd.Flags().UintVarP(&pcmHz, "pcm-rate", "p", 0, "PCM sampling rate in Hz")
+	// Commands share the variable, so the default is left to dsp.
+	cmd.Flags().UintVarP(&bandwidthHz, "bandwidth", "b", 0, fmt.Sprintf("Audio bandwidth in Hz (default %g)", bwHz))
+	cmd.Flags().Float64VarP(&bfoHz, "bfo", "", 0, "Carrier offset from the center frequency in Hz")
+	addFlagBand(cmd)
 }
 
 func mustOpenIQW(outf string) (*radio.IQWriter, func()) {
@@ -104,8 +166,40 @@ func demod(inf, outf string) {
 	demodc := dsp.DemodFM(float32(h), iqr.Batch64(512, 0))
 	r := float64(pcmHz) / float64(iqr.Width)
 	resampc := dsp.Resample(float32(r), demodc)
+	writePCM(writer, resampc)
+}
+
+type audioDemodFunc func(dsp.AudioConfig, <-chan []complex64) <-chan []float32
+
+func audioDemod(f audioDemodFunc, inf, outf string) {
+	if flagBand.Width == 0 || pcmHz == 0 {
+		panic("need sample-rate and pcm-rate")
+	}
+	iqr, rcloser := mustOpenInput(inf)
+	defer rcloser()
+
+	outBand := radio.HzBand{Center: iqr.Center, Width: uint64(pcmHz)}
+	writer, wcloser, err := nicerx.OpenOutputS16(outf, outBand)
+	if err != nil {
+		panic(err)
+	}
+	defer wcloser()
+
+	cfg := dsp.AudioConfig{
+		SampleHz:    int(iqr.Width),
+		AudioHz:     int(pcmHz),
+		BandwidthHz: float64(bandwidthHz),
+		BFOHz:       bfoHz,
+		ToneHz:      toneHz,
+		LSB:         lsb,
+	}
+	writePCM(writer, f(cfg, iqr.Batch64(512, 0)))
+}
+
+// writePCM scales samples to the range seen so far and writes them as s16.
+func writePCM(writer io.Writer, pcmc <-chan []float32) {
 	min, max := float32(0), float32(0)
-	for rsamps := range resampc {
+	for rsamps := range pcmc {
 		outsamps := make([]int16, len(rsamps))
 		for i, v := range rsamps {
 			if min > v && v == v {
@@ -129,6 +223,17 @@ func spectrogram(inf, outf string) {
 	}
 }
 
+func heatmap(inf, outf string) {
+	cm, err := nicerx.ParseColormap(colormap)
+	if err != nil {
+		panic(err)
+	}
+	heatmapCfg.Colormap = cm
+	if err := nicerx.WriteHeatmapFile(inf, outf, heatmapCfg); err != nil {
+		panic(err)
+	}
+}
+
 type xfmFunc func(iqr *radio.MixerIQReader, iqw *radio.IQWriter)
 
 func applyXfmCmd(xf xfmFunc, inf, outf string) {
@@ -281,7 +281,11 @@ func (fw *fftWindow) screenshot() error {
 }
 
 func (fw *fftWindow) toggleWrite(useFifo bool) {
-	path := fmt.Sprintf("%d[%d].%s", fw.iqr.Center, fw.iqr.Width, outputExt)
+	ext := outputExt
+	if ext == "sigmf" {
+		ext = "sigmf-data"
+	}
+	path := fmt.Sprintf("%d[%d].%s", fw.iqr.Center, fw.iqr.Width, ext)
 	if fw.wdonec != nil {
 		fw.wcancel()
 		<-fw.wdonec
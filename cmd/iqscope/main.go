@@ -24,14 +24,14 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	fftCmd := &cobra.Command{
-		Use:   "fft [flags] [input.iq8|sdr://host/device]",
+		Use:   "fft [flags] [input.iq8|input.sigmf-meta|sdr://host/device]",
 		Short: "Stream FFT waterfall",
 		Run:   func(cmd *cobra.Command, args []string) { fftCmd(args) },
 	}
 
 	fftCmd.Flags().Uint64VarP(&flagBand.Center, "center-hz", "c", 0, "Center Frequency in Hz")
 	fftCmd.Flags().Uint64VarP(&flagBand.Width, "sample-rate", "s", 2048000, "Sample rate in Hz")
-	fftCmd.Flags().StringVarP(&outputExt, "output-format", "f", "wav", "Output wav or iq8")
+	fftCmd.Flags().StringVarP(&outputExt, "output-format", "f", "wav", "Output wav, iq8, or sigmf")
 
 	// UI
 	fftCmd.Flags().IntVarP(&winWidth, "window-width", "w", 600, "Total FFT buckets / window width")
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -29,14 +30,59 @@ var (
 	powerFFTs   int
 	imageWidth  int
 	pcmHz       uint
+	radioSerial string
+	calPath     string
+	profile     string
+	profilePath string
+	calRef      string
+	driftEvery  time.Duration
+	sweepCfg    radio.SweepConfig
+	sweepLoHz   uint64
+	sweepHiHz   uint64
+	sweepWindow string
+	sweepPasses int
+	sweepCSV    string
 )
 
+func addSweepFlags(cmd *cobra.Command) {
+	d := radio.DefaultSweepConfig
+	cmd.Flags().Uint64VarP(&sweepLoHz, "start", "", 0, "Lowest frequency to sweep in Hz")
+	cmd.Flags().Uint64VarP(&sweepHiHz, "stop", "", 0, "Highest frequency to sweep in Hz")
+	cmd.Flags().Uint32VarP(&sweepCfg.SampleRate, "sample-rate", "s", d.SampleRate, "Sample rate of each hop in Hz")
+	cmd.Flags().IntVarP(&sweepCfg.Bins, "bins", "", d.Bins, "FFT bins per hop")
+	cmd.Flags().Float64VarP(&sweepCfg.Overlap, "overlap", "", d.Overlap, "Fraction of each hop dropped at the edges")
+	cmd.Flags().DurationVarP(&sweepCfg.Dwell, "dwell", "", d.Dwell, "Integration time per hop")
+	cmd.Flags().StringVarP(&sweepWindow, "window", "", string(radio.WindowHann), "FFT window: rect, hann, blackman_harris, or flattop")
+}
+
+func sweepConfig() (radio.SweepConfig, error) {
+	w, err := radio.ParseWindow(sweepWindow)
+	if err != nil {
+		return radio.SweepConfig{}, err
+	}
+	cfg := sweepCfg
+	cfg.Spectral = radio.DefaultSpectralConfig
+	cfg.Spectral.Window = w
+	cfg.Range = radio.HzBandRange(int64(sweepLoHz), int64(sweepHiHz))
+	return cfg, nil
+}
+
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	rootCmd.PersistentFlags().StringVarP(&radioSerial, "radio", "", "0", "Radio serial, index, tcp://host:port, or sim:")
+	rootCmd.PersistentFlags().StringVarP(&calPath, "calibration", "", radio.DefaultCalibrationPath(), "Per-radio ppm calibration table")
+	rootCmd.PersistentFlags().StringVarP(&profilePath, "profiles", "", radio.DefaultProfilePath(), "Per-radio device profiles")
+	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "", "", "Radio front end: direct:<i|q|off> or upconverter:<lo hz>")
+
+	serveCmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Start the server",
 		Run:   func(cmd *cobra.Command, args []string) { serve() },
-	})
+	}
+	serveCmd.Flags().DurationVarP(&driftEvery, "drift-interval", "", 10*time.Minute, "Time between drift measurements; 0 disables")
+	serveCmd.Flags().StringVarP(&calRef, "drift-reference", "", "noaa", "noaa, fm_pilot:<hz>, beacon:<hz>, or gsm:<hz>")
+	serveCmd.Flags().StringVarP(&sweepCSV, "sweep-csv", "", "", "Continuously sweep the --start/--stop range into this CSV")
+	addSweepFlags(serveCmd)
+	rootCmd.AddCommand(serveCmd)
 
 	captureCmd := &cobra.Command{
 		Use:   "capture [flags]",
@@ -47,6 +93,24 @@ func init() {
 	captureCmd.Flags().UintVarP(&bandwidthHz, "bandwidth", "b", 0, "Bandwidth to capture in Hz")
 	rootCmd.AddCommand(captureCmd)
 
+	calibrateCmd := &cobra.Command{
+		Use:   "calibrate [flags]",
+		Short: "Measure and save the radio's frequency error",
+		Run:   func(cmd *cobra.Command, args []string) { calibrate() },
+	}
+	calibrateCmd.Flags().StringVarP(&calRef, "reference", "r", "noaa", "noaa, fm_pilot:<hz>, beacon:<hz>, or gsm:<hz>")
+	rootCmd.AddCommand(calibrateCmd)
+
+	sweepCmd := &cobra.Command{
+		Use:   "sweep [flags] output.csv",
+		Short: "Sweep a frequency range into rtl_power CSV",
+		Args:  cobra.ExactArgs(1),
+		Run:   func(cmd *cobra.Command, args []string) { sweep(args[0]) },
+	}
+	addSweepFlags(sweepCmd)
+	sweepCmd.Flags().IntVarP(&sweepPasses, "passes", "", 1, "Number of sweeps; 0 sweeps until interrupted")
+	rootCmd.AddCommand(sweepCmd)
+
 	importCmd := &cobra.Command{
 		Use:   "import csvfile",
 		Short: "Import gqrx csv file into bands.db",
@@ -101,6 +165,58 @@ func spectrogram(inf, outf string) {
 	}
 }
 
+// openSDR opens the radio with its profile and saved calibration applied.
+func openSDR(ctx context.Context) (radio.SDR, *radio.CalibrationTable, error) {
+	cal, err := radio.LoadCalibrationTable(calPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	tbl, err := radio.LoadProfileTable(profilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	ser := tbl.Resolve(radioSerial)
+	prof := tbl.Get(ser)
+	if profile != "" {
+		hf, err := radio.ParseDeviceProfile(profile)
+		if err != nil {
+			return nil, nil, err
+		}
+		prof.DirectSampling, prof.UpconverterHz = hf.DirectSampling, hf.UpconverterHz
+		tbl.Set(ser, prof)
+	}
+	sdr, err := tbl.Open(ctx, ser)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A pinned correction overrides calibrations.
+	if prof.PPM != nil {
+		return sdr, cal, nil
+	}
+	if err := cal.Apply(sdr); err != nil {
+		sdr.Close()
+		return nil, nil, err
+	}
+	return sdr, cal, nil
+}
+
+func calibrate() {
+	ref, err := radio.ParseCalReference(calRef)
+	if err != nil {
+		panic(err)
+	}
+	sdr, cal, err := openSDR(context.TODO())
+	if err != nil {
+		panic(err)
+	}
+	defer sdr.Close()
+	c, err := cal.Calibrate(sdr, ref)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s: %.2fppm\n", sdr.Info().Id, c.PPM)
+}
+
 func capture() {
 	if centerHz == 0 {
 		panic("need center frequency")
@@ -108,7 +224,7 @@ func capture() {
 	if bandwidthHz == 0 {
 		panic("need bandwidth")
 	}
-	sdr, err := radio.NewSDR(context.TODO())
+	sdr, _, err := openSDR(context.TODO())
 	if err != nil {
 		panic(err)
 	}
@@ -124,9 +240,44 @@ func capture() {
 	}
 }
 
+func sweep(outf string) {
+	cfg, err := sweepConfig()
+	if err != nil {
+		panic(err)
+	}
+	w := os.Stdout
+	if outf != "-" {
+		if w, err = os.Create(outf); err != nil {
+			panic(err)
+		}
+		defer w.Close()
+	}
+	sdr, _, err := openSDR(context.TODO())
+	if err != nil {
+		panic(err)
+	}
+	defer sdr.Close()
+	st, err := nicerx.NewSweepTask(sdr, cfg, w)
+	if err != nil {
+		panic(err)
+	}
+	st.Passes = sweepPasses
+	for {
+		if err := st.Step(context.TODO()); err == io.EOF {
+			return
+		} else if err != nil {
+			panic(err)
+		}
+	}
+}
+
 func serve() {
+	ref, err := radio.ParseCalReference(calRef)
+	if err != nil {
+		panic(err)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
-	sdr, err := radio.NewSDR(ctx)
+	sdr, cal, err := openSDR(ctx)
 	if err != nil {
 		panic(err)
 	}
@@ -138,7 +289,28 @@ func serve() {
 	if err != nil {
 		panic(err)
 	}
-	go func() { s.Serve(ctx) }()
+	if driftEvery > 0 {
+		s.MonitorDrift(radio.DriftConfig{Reference: ref, Interval: driftEvery}, cal)
+	}
+	if sweepCSV != "" {
+		cfg, err := sweepConfig()
+		if err != nil {
+			panic(err)
+		}
+		f, err := os.OpenFile(sweepCSV, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		if err := s.Sweep(cfg, f, 0); err != nil {
+			panic(err)
+		}
+	}
+	go func() {
+		if err := s.Serve(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("serve:", err)
+		}
+	}()
 	fmt.Println("serving http on :8080...")
 	if err := http.ServeHttp(s, ":8080"); err != nil {
 		fmt.Println(err)
@@ -14,8 +14,13 @@ import (
 )
 
 var (
-	endpoint string
-	minKHz   int
+	endpoint   string
+	minKHz     int
+	window     string
+	overlap    float64
+	averaging  string
+	cfarMethod string
+	pfa        float64
 )
 
 var rootCmd = &cobra.Command{
@@ -27,9 +32,30 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.Flags().StringVarP(&endpoint, "url", "", "http://localhost:12000", "URL for sdrproxy")
 	rootCmd.Flags().IntVarP(&minKHz, "min-khz", "", 5, "Minimum KHz to inspect for signal")
+	rootCmd.Flags().StringVarP(&window, "window", "", "hann", "FFT window: rect, hann, blackman_harris, or flattop")
+	rootCmd.Flags().Float64VarP(&overlap, "overlap", "", 0.5, "Fraction of each FFT overlapping the next")
+	rootCmd.Flags().StringVarP(&averaging, "averaging", "", "mean", "FFT averaging: mean, exp, or peak")
+	rootCmd.Flags().StringVarP(&cfarMethod, "cfar", "", "os", "CFAR detector: ca (cell averaging) or os (order statistic)")
+	rootCmd.Flags().Float64VarP(&pfa, "pfa", "", radio.DefaultCFARConfig.Pfa, "CFAR false alarm probability")
 }
 
-func doMonitor(ctx context.Context, c *client.Client, r radio.SDRHWInfo, sigs []sdrproxy.RxSignal) {
+func spectralConfig() (cfg radio.SpectralConfig, ccfg radio.CFARConfig, err error) {
+	if cfg.Window, err = radio.ParseWindow(window); err != nil {
+		return cfg, ccfg, err
+	}
+	if cfg.Averaging, err = radio.ParseAveraging(averaging); err != nil {
+		return cfg, ccfg, err
+	}
+	cfg.Overlap = overlap
+	ccfg = radio.DefaultCFARConfig
+	if ccfg.Method, err = radio.ParseCFARMethod(cfarMethod); err != nil {
+		return cfg, ccfg, err
+	}
+	ccfg.Pfa = pfa
+	return cfg, ccfg, nil
+}
+
+func doMonitor(ctx context.Context, c *client.Client, r radio.SDRHWInfo, sigs []sdrproxy.RxSignal, cfg radio.SpectralConfig, ccfg radio.CFARConfig) {
 	log.Printf("monitoring %+v", r.Id)
 	band := radio.HzBand{Center: r.CenterHz, Width: uint64(r.SampleRate)}
 	rxreq := sdrproxy.RxRequest{
@@ -54,7 +80,7 @@ func doMonitor(ctx context.Context, c *client.Client, r radio.SDRHWInfo, sigs []
 
 	// Split into 500hz chunks; 2ms windows.
 	bins := int(r.SampleRate / 500)
-	sp := radio.NewSpectralPower(band.ToMHz(), bins, 20)
+	sp := radio.NewSpectralPowerConfig(band.ToMHz(), bins, 20, cfg)
 	iqrc := iqr.BatchStream64(ctx, bins, 0)
 	if row := <-iqrc; row == nil {
 		panic("could not read first row")
@@ -67,7 +93,8 @@ func doMonitor(ctx context.Context, c *client.Client, r radio.SDRHWInfo, sigs []
 			if err := sp.Measure(iqrc); err != nil {
 				panic(err)
 			}
-			for _, v := range sp.Bands() {
+			for _, det := range sp.Detect(ccfg) {
+				v := det.FreqBand
 				if int(v.BandwidthKHz()) < minKHz {
 					continue
 				}
@@ -94,6 +121,10 @@ func run() {
 	if err != nil {
 		panic(err)
 	}
+	cfg, ccfg, err := spectralConfig()
+	if err != nil {
+		panic(err)
+	}
 	c := client.New(*u)
 	log.Println(u.String())
 	defer c.Close()
@@ -121,7 +152,7 @@ func run() {
 		rr := r
 		go func() {
 			defer wg.Done()
-			doMonitor(cctx, c, rr, sigs2sdr[rr.Id])
+			doMonitor(cctx, c, rr, sigs2sdr[rr.Id], cfg, ccfg)
 		}()
 	}
 	wg.Wait()
@@ -2,20 +2,71 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/chzchzchz/nicerx/radio"
 	"github.com/chzchzchz/nicerx/sdrproxy/http"
 	"github.com/chzchzchz/nicerx/sdrproxy/server"
 )
 
 var bindServ = flag.String("bind", "localhost:12000", "address to bind server")
+var calPath = flag.String("calibration", radio.DefaultCalibrationPath(), "per-radio ppm calibration table")
+var driftEvery = flag.Duration("drift-interval", 10*time.Minute, "time between drift measurements of idle radios; 0 disables")
+var profilePath = flag.String("profiles", radio.DefaultProfilePath(), "per-radio device profiles")
+var driftRef = flag.String("drift-reference", "noaa", "noaa, fm_pilot:<hz>, beacon:<hz>, or gsm:<hz>")
+
+// profileFlags are repeated "serial=profile" front end settings.
+type profileFlags map[string]radio.DeviceProfile
+
+func (pf profileFlags) String() string { return fmt.Sprint(map[string]radio.DeviceProfile(pf)) }
+
+func (pf profileFlags) Set(v string) error {
+	id, p, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("expected serial=profile, got %q", v)
+	}
+	prof, err := radio.ParseDeviceProfile(p)
+	if err != nil {
+		return err
+	}
+	pf[id] = prof
+	return nil
+}
+
+var profiles = make(profileFlags)
 
 func main() {
+	flag.Var(profiles, "profile", "radio front end as serial=direct:<i|q|off> or serial=upconverter:<lo hz>; repeatable")
 	flag.Parse()
 
 	log.SetFlags(log.Lmsgprefix | log.LstdFlags)
 	log.Printf("listening on %s", *bindServ)
 	s := server.NewServer()
+	cal, err := radio.LoadCalibrationTable(*calPath)
+	if err != nil {
+		panic(err)
+	}
+	s.SetCalibrationTable(cal)
+	tbl, err := radio.LoadProfileTable(*profilePath)
+	if err != nil {
+		panic(err)
+	}
+	for id, hf := range profiles {
+		p := tbl.Get(id)
+		p.DirectSampling, p.UpconverterHz = hf.DirectSampling, hf.UpconverterHz
+		tbl.Set(id, p)
+	}
+	s.SetProfileTable(tbl)
+	if *driftEvery > 0 {
+		ref, err := radio.ParseCalReference(*driftRef)
+		if err != nil {
+			panic(err)
+		}
+		s.SetDriftConfig(radio.DriftConfig{Reference: ref, Interval: *driftEvery})
+	}
 	if err := http.ServeHttp(s, *bindServ); err != nil {
 		panic(err)
 	}
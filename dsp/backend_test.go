@@ -0,0 +1,120 @@
+//go:build cgo && !purego
+
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// These compare the liquid-dsp functions with the pure Go objects used by
+// the purego build.
+
+// noise is band limited complex noise, a sum of random tones.
+func noise(n int) []complex64 {
+	rng := rand.New(rand.NewSource(1))
+	ret := make([]complex64, n)
+	for k := 0; k < 20; k++ {
+		f, a, p := rng.Float64()-0.5, rng.Float64()/10, rng.Float64()*2*math.Pi
+		for i := range ret {
+			ret[i] += complex64(cmplx.Rect(a, 2*math.Pi*f*float64(i)+p))
+		}
+	}
+	return ret
+}
+
+// blocks applies f to samps in blocks of 1000.
+func blocks[O any](samps []complex64, f func([]complex64) []O) (ret []O) {
+	for len(samps) > 0 {
+		n := min(1000, len(samps))
+		ret = append(ret, f(samps[:n])...)
+		samps = samps[n:]
+	}
+	return ret
+}
+
+func maxErr(t *testing.T, a, b []complex64, skip int) float64 {
+	if len(a) != len(b) {
+		t.Fatalf("lengths differ: %d vs %d", len(a), len(b))
+	}
+	worst := 0.0
+	for i := skip; i < len(a); i++ {
+		worst = math.Max(worst, cmplx.Abs(complex128(a[i]-b[i])))
+	}
+	return worst
+}
+
+func TestBackendsMixDown(t *testing.T) {
+	in := noise(10000)
+	cgo := stream(in, func(c <-chan []complex64) <-chan []complex64 { return MixDown(-123456, 1e6, c) })
+	q := newNCO(2*math.Pi - 123456*2*math.Pi/1e6)
+	native := blocks(in, func(b []complex64) []complex64 {
+		out := make([]complex64, len(b))
+		q.mixBlockDown(b, out)
+		return out
+	})
+	if e := maxErr(t, cgo, native, 0); e > 1e-2 {
+		t.Fatalf("max error %g", e)
+	}
+}
+
+func TestBackendsLowpass(t *testing.T) {
+	in := noise(10000)
+	cgo := stream(in, func(c <-chan []complex64) <-chan []complex64 { return Lowpass(100e3, 1e6, 4, c) })
+	q := newFIRFilter(firKaiser(64, 0.1, 70, 0), 0.2)
+	native := blocks(in, func(b []complex64) []complex64 { return q.block(b, 4) })
+	if e := maxErr(t, cgo, native, 16); e > 1e-2 {
+		t.Fatalf("max error %g", e)
+	}
+}
+
+func TestBackendsDemodFM(t *testing.T) {
+	in := noise(10000)
+	cgo := stream(in, func(c <-chan []complex64) <-chan []float32 { return DemodFM(0.1, c) })
+	q := &freqDem{kf: 0.1}
+	native := blocks(in, func(b []complex64) []float32 {
+		out := make([]float32, len(b))
+		q.block(b, out)
+		return out
+	})
+	if e := maxErr(t, toComplex(cgo), toComplex(native), 1); e > 1e-3 {
+		t.Fatalf("max error %g", e)
+	}
+}
+
+func TestBackendsDCBlocker(t *testing.T) {
+	in := noise(10000)
+	cgo := stream(in, func(c <-chan []complex64) <-chan []complex64 { return DCBlockerCtx(t.Context(), c) })
+	q := newDCBlocker(0.1)
+	native := blocks(in, func(b []complex64) []complex64 {
+		out := make([]complex64, len(b))
+		q.block(b, out)
+		return out
+	})
+	if e := maxErr(t, cgo, native, 0); e > 1e-2 {
+		t.Fatalf("max error %g", e)
+	}
+}
+
+// The resamplers' filters differ, so compare what comes out rather than
+// each sample.
+func TestBackendsResample(t *testing.T) {
+	const fs = 48000.0
+	in := tone(3000, fs, 48000)
+	for _, r := range []float32{0.5, 1.5} {
+		cgo := stream(in, func(c <-chan []complex64) <-chan []complex64 { return ResampleComplex64(r, c) })
+		native := blocks(in, newResampler(float64(r)).block)
+		if d := len(cgo) - len(native); d < -20 || d > 20 {
+			t.Errorf("%g: %d vs %d samples", r, len(cgo), len(native))
+		}
+		a, b := toneHz(cgo[100:], fs*float64(r)), toneHz(native[100:], fs*float64(r))
+		if math.Abs(a-b) > 1 {
+			t.Errorf("%g: tone at %.2fHz vs %.2fHz", r, a, b)
+		}
+		if pa, pb := rms(cgo[100:]), rms(native[100:]); math.Abs(20*math.Log10(pa/pb)) > 0.5 {
+			t.Errorf("%g: power differs, %.3f vs %.3f", r, pa, pb)
+		}
+	}
+}
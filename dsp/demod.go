@@ -0,0 +1,252 @@
+package dsp
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+)
+
+// AudioConfig sets up AM, SSB, and CW demodulation of complex baseband.
+type AudioConfig struct {
+	// SampleHz is the input sample rate.
+	SampleHz int
+	// AudioHz is the output sample rate.
+	AudioHz int
+	// BandwidthHz is the audio bandwidth: each side of the carrier for
+	// AM, the sideband width for SSB, and the filter width for CW.
+	BandwidthHz float64
+	// BFOHz is the carrier's offset from the center of the input.
+	BFOHz float64
+	// ToneHz is the pitch of a CW signal.
+	ToneHz float64
+	// LSB demodulates the lower sideband instead of the upper.
+	LSB bool
+}
+
+var DefaultAMBandwidthHz = 5000.0
+var DefaultSSBBandwidthHz = 2700.0
+var DefaultCWBandwidthHz = 250.0
+var DefaultCWToneHz = 700.0
+
+const (
+	// demodAs is the stopband attenuation of the demodulator filters.
+	demodAs = 60
+	// demodMaxTaps caps filter lengths for very narrow bands.
+	demodMaxTaps = 4095
+	// demodDCSeconds is the time constant of the AM carrier removal.
+	demodDCSeconds = 0.1
+	// pllHz is the loop bandwidth of the synchronous AM carrier tracker.
+	pllHz = 30
+)
+
+// lowpassTaps designs a filter passing cutoffHz and rejecting from stopHz.
+func lowpassTaps(cutoffHz, stopHz, sampHz float64) ([]float32, float32) {
+	df := (stopHz - cutoffHz) / sampHz
+	n := int(math.Ceil((demodAs-8)/(14.36*df))) | 1
+	n = min(n, demodMaxTaps)
+	fc := (cutoffHz + stopHz) / 2 / sampHz
+	return firKaiser(n, fc, demodAs, 0), float32(2 * fc)
+}
+
+// audioDemod is the part shared by the demodulators: it moves the carrier
+// to zero, decimates to a rate a few times the bandwidth, and resamples
+// the demodulated audio to the output rate.
+type audioDemod struct {
+	bfo    *nco
+	dec    *firFilter
+	decN   int
+	rateHz float64
+	resamp *resampler
+}
+
+func newAudioDemod(cfg AudioConfig, bwHz float64) *audioDemod {
+	fs := float64(cfg.SampleHz)
+	bfo := math.Mod(cfg.BFOHz*2*math.Pi/fs+2*math.Pi, 2*math.Pi)
+	ad := &audioDemod{bfo: newNCO(bfo), decN: max(1, int(fs/(4*bwHz)))}
+	ad.rateHz = fs / float64(ad.decN)
+	if ad.decN > 1 {
+		ad.dec = newFIRFilter(lowpassTaps(bwHz, 2*bwHz, fs))
+	}
+	if ad.rateHz != float64(cfg.AudioHz) {
+		ad.resamp = newResampler(float64(cfg.AudioHz) / ad.rateHz)
+	}
+	return ad
+}
+
+// baseband mixes and decimates a block.
+func (ad *audioDemod) baseband(in []complex64) []complex64 {
+	mixed := make([]complex64, len(in))
+	ad.bfo.mixBlockDown(in, mixed)
+	if ad.dec == nil {
+		return mixed
+	}
+	return ad.dec.block(mixed, ad.decN)
+}
+
+// audio resamples demodulated samples to the output rate.
+func (ad *audioDemod) audio(samps []float32) []float32 {
+	if ad.resamp == nil {
+		return samps
+	}
+	in := make([]complex64, len(samps))
+	for i, v := range samps {
+		in[i] = complex(v, 0)
+	}
+	out := ad.resamp.block(in)
+	ret := make([]float32, len(out))
+	for i, v := range out {
+		ret[i] = real(v)
+	}
+	return ret
+}
+
+func (cfg AudioConfig) withBandwidth(bwHz float64) AudioConfig {
+	if cfg.BandwidthHz <= 0 {
+		cfg.BandwidthHz = bwHz
+	}
+	if cfg.AudioHz <= 0 {
+		cfg.AudioHz = cfg.SampleHz
+	}
+	return cfg
+}
+
+// dcRemover tracks and subtracts the mean of a real signal.
+type dcRemover struct {
+	alpha, mean float64
+}
+
+func newDCRemover(rateHz float64) *dcRemover {
+	return &dcRemover{alpha: 1 / (demodDCSeconds * rateHz)}
+}
+
+func (q *dcRemover) remove(v float64) float32 {
+	q.mean += q.alpha * (v - q.mean)
+	return float32(v - q.mean)
+}
+
+// DemodAM demodulates AM by its envelope.
+func DemodAM(cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	return DemodAMCtx(context.TODO(), cfg, sigc)
+}
+
+func DemodAMCtx(ctx context.Context, cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	cfg = cfg.withBandwidth(DefaultAMBandwidthHz)
+	ad := newAudioDemod(cfg, cfg.BandwidthHz)
+	dc := newDCRemover(ad.rateHz)
+	return pipe(ctx, sigc, func(samps []complex64) []float32 {
+		bb := ad.baseband(samps)
+		out := make([]float32, len(bb))
+		for i, v := range bb {
+			out[i] = dc.remove(cmplx.Abs(complex128(v)))
+		}
+		return ad.audio(out)
+	})
+}
+
+// DemodSyncAM demodulates AM by locking to its carrier, which holds up
+// better than the envelope through selective fading. The carrier may be
+// off by tens of Hz from BFOHz.
+func DemodSyncAM(cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	return DemodSyncAMCtx(context.TODO(), cfg, sigc)
+}
+
+func DemodSyncAMCtx(ctx context.Context, cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	cfg = cfg.withBandwidth(DefaultAMBandwidthHz)
+	ad := newAudioDemod(cfg, cfg.BandwidthHz)
+	dc := newDCRemover(ad.rateHz)
+	// A second order loop, damped at 0.707.
+	wn := 2 * math.Pi * pllHz / ad.rateHz
+	alpha, beta := 2*0.707*wn, wn*wn
+	phase, freq := 0.0, 0.0
+	return pipe(ctx, sigc, func(samps []complex64) []float32 {
+		bb := ad.baseband(samps)
+		out := make([]float32, len(bb))
+		for i, v := range bb {
+			y := complex128(v) * cmplx.Rect(1, -phase)
+			err := 0.0
+			if a := cmplx.Abs(y); a > 0 {
+				err = imag(y) / a
+			}
+			freq += beta * err
+			phase = math.Mod(phase+freq+alpha*err, 2*math.Pi)
+			out[i] = dc.remove(real(y))
+		}
+		return ad.audio(out)
+	})
+}
+
+// weaver shifts a band of width bw starting at zero down to be centered
+// on zero, keeps it, and shifts it back up to take the real part. Mirrored
+// bands, like the lower sideband, are shifted with negative frequencies.
+type weaver struct {
+	down, up *nco
+	lpf      *firFilter
+}
+
+func newWeaver(shiftHz, bwHz, rateHz float64) *weaver {
+	w := shiftHz * 2 * math.Pi / rateHz
+	// The other sideband starts right at the passband edge.
+	return &weaver{
+		down: newNCO(math.Mod(w+2*math.Pi, 2*math.Pi)),
+		up:   newNCO(math.Mod(-w+2*math.Pi, 2*math.Pi)),
+		lpf:  newFIRFilter(lowpassTaps(bwHz/2, bwHz/2+math.Max(50, bwHz/10), rateHz)),
+	}
+}
+
+func (q *weaver) block(in []complex64) []float32 {
+	mixed := make([]complex64, len(in))
+	q.down.mixBlockDown(in, mixed)
+	filtered := q.lpf.block(mixed, 1)
+	q.up.mixBlockDown(filtered, filtered)
+	out := make([]float32, len(filtered))
+	for i, v := range filtered {
+		out[i] = real(v)
+	}
+	return out
+}
+
+// DemodSSB demodulates single sideband with the Weaver method; BFOHz
+// moves the suppressed carrier and LSB selects the lower sideband.
+func DemodSSB(cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	return DemodSSBCtx(context.TODO(), cfg, sigc)
+}
+
+func DemodSSBCtx(ctx context.Context, cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	cfg = cfg.withBandwidth(DefaultSSBBandwidthHz)
+	ad := newAudioDemod(cfg, cfg.BandwidthHz)
+	shift := cfg.BandwidthHz / 2
+	if cfg.LSB {
+		shift = -shift
+	}
+	wv := newWeaver(shift, cfg.BandwidthHz, ad.rateHz)
+	return pipe(ctx, sigc, func(samps []complex64) []float32 {
+		return ad.audio(wv.block(ad.baseband(samps)))
+	})
+}
+
+// DemodCW keeps BandwidthHz around the carrier at BFOHz and plays it as a
+// ToneHz note.
+func DemodCW(cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	return DemodCWCtx(context.TODO(), cfg, sigc)
+}
+
+func DemodCWCtx(ctx context.Context, cfg AudioConfig, sigc <-chan []complex64) <-chan []float32 {
+	cfg = cfg.withBandwidth(DefaultCWBandwidthHz)
+	if cfg.ToneHz <= 0 {
+		cfg.ToneHz = DefaultCWToneHz
+	}
+	// Decimate to leave room for the tone.
+	ad := newAudioDemod(cfg, cfg.ToneHz+cfg.BandwidthHz)
+	lpf := newFIRFilter(lowpassTaps(cfg.BandwidthHz/2, cfg.BandwidthHz, ad.rateHz))
+	w := cfg.ToneHz * 2 * math.Pi / ad.rateHz
+	tone := newNCO(2*math.Pi - w)
+	return pipe(ctx, sigc, func(samps []complex64) []float32 {
+		bb := lpf.block(ad.baseband(samps), 1)
+		tone.mixBlockDown(bb, bb)
+		out := make([]float32, len(bb))
+		for i, v := range bb {
+			out[i] = real(v)
+		}
+		return ad.audio(out)
+	})
+}
@@ -0,0 +1,108 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// toneAmp is the amplitude of hz in real samples.
+func toneAmp(samps []float32, fs, hz float64) float64 {
+	var sum complex128
+	for i, v := range samps {
+		sum += complex(float64(v), 0) * cmplx.Rect(1, -2*math.Pi*hz*float64(i)/fs)
+	}
+	return 2 * cmplx.Abs(sum) / float64(len(samps))
+}
+
+// rejection is how far below the wanted tone the unwanted one is, in dB.
+func rejection(samps []float32, fs, want, unwanted float64) float64 {
+	return 20 * math.Log10(toneAmp(samps, fs, want)/toneAmp(samps, fs, unwanted))
+}
+
+const (
+	demodFs    = 240000.0
+	demodAudio = 8000.0
+)
+
+func demodCfg() AudioConfig { return AudioConfig{SampleHz: demodFs, AudioHz: demodAudio} }
+
+// tones sums complex tones at (hz, amplitude) pairs.
+func tones(n int, ts ...[2]float64) []complex64 {
+	ret := make([]complex64, n)
+	for _, t := range ts {
+		for i := range ret {
+			ret[i] += complex64(cmplx.Rect(t[1], 2*math.Pi*t[0]*float64(i)/demodFs))
+		}
+	}
+	return ret
+}
+
+func TestDemodAM(t *testing.T) {
+	// A carrier 2kHz up, 50% modulated by 1kHz, slightly off from the BFO.
+	const carrier, mod = 2000.0, 1000.0
+	in := make([]complex64, int(demodFs))
+	for i := range in {
+		ts := float64(i) / demodFs
+		a := 1 + 0.5*math.Sin(2*math.Pi*mod*ts)
+		in[i] = complex64(cmplx.Rect(0.5*a, 2*math.Pi*carrier*ts+0.3))
+	}
+	for name, demod := range map[string]func(AudioConfig, <-chan []complex64) <-chan []float32{
+		"envelope": DemodAM,
+		"sync":     DemodSyncAM,
+	} {
+		cfg := demodCfg()
+		cfg.BFOHz = carrier - 20
+		out := stream(in, func(c <-chan []complex64) <-chan []float32 { return demod(cfg, c) })
+		if n := len(out); math.Abs(float64(n)-demodAudio) > 50 {
+			t.Fatalf("%s: got %d samples, expected %.0f", name, n, demodAudio)
+		}
+		// Skip the loop and filters settling.
+		out = out[len(out)/2:]
+		if a := toneAmp(out, demodAudio, mod); math.Abs(a-0.25) > 0.03 {
+			t.Errorf("%s: tone amplitude %.3f, expected 0.25", name, a)
+		}
+		if r := rejection(out, demodAudio, mod, 2*mod); r < 30 {
+			t.Errorf("%s: distortion only %.1fdB down", name, r)
+		}
+	}
+}
+
+func TestDemodSSB(t *testing.T) {
+	// A 1kHz note on the upper sideband and 1.5kHz on the lower, around a
+	// suppressed carrier 10kHz down.
+	const carrier = -10000.0
+	in := tones(int(demodFs), [2]float64{carrier + 1000, 0.3}, [2]float64{carrier - 1500, 0.3})
+	for _, lsb := range []bool{false, true} {
+		cfg := demodCfg()
+		cfg.BFOHz, cfg.LSB = carrier, lsb
+		out := stream(in, func(c <-chan []complex64) <-chan []float32 { return DemodSSB(cfg, c) })
+		out = out[len(out)/4:]
+		want, unwanted := 1000.0, 1500.0
+		if lsb {
+			want, unwanted = unwanted, want
+		}
+		if a := toneAmp(out, demodAudio, want); math.Abs(a-0.3) > 0.03 {
+			t.Errorf("lsb=%v: tone amplitude %.3f, expected 0.3", lsb, a)
+		}
+		if r := rejection(out, demodAudio, want, unwanted); r < 40 {
+			t.Errorf("lsb=%v: opposite sideband only %.1fdB down", lsb, r)
+		}
+	}
+}
+
+func TestDemodCW(t *testing.T) {
+	// The wanted carrier and another 500Hz away.
+	const carrier = 5000.0
+	in := tones(int(demodFs), [2]float64{carrier, 0.2}, [2]float64{carrier + 500, 0.2})
+	cfg := demodCfg()
+	cfg.BFOHz, cfg.ToneHz = carrier, 600
+	out := stream(in, func(c <-chan []complex64) <-chan []float32 { return DemodCW(cfg, c) })
+	out = out[len(out)/4:]
+	if a := toneAmp(out, demodAudio, 600); math.Abs(a-0.2) > 0.02 {
+		t.Errorf("tone amplitude %.3f, expected 0.2", a)
+	}
+	if r := rejection(out, demodAudio, 600, 1100); r < 40 {
+		t.Errorf("neighbor only %.1fdB down", r)
+	}
+}
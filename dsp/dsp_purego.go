@@ -0,0 +1,93 @@
+//go:build purego || !cgo
+
+package dsp
+
+import (
+	"context"
+	"math"
+)
+
+func MixDown(mixHz float64, sampHz int, sigc <-chan []complex64) <-chan []complex64 {
+	return MixDownCtx(context.TODO(), mixHz, sampHz, sigc)
+}
+
+func MixDownCtx(ctx context.Context, mixHz float64, sampHz int, sigc <-chan []complex64) <-chan []complex64 {
+	radiansPerSample := mixHz * (2.0 * math.Pi / float64(sampHz))
+	if radiansPerSample < 0 {
+		radiansPerSample += 2.0 * math.Pi
+	}
+	q := newNCO(radiansPerSample)
+	return pipe(ctx, sigc, func(samp []complex64) []complex64 {
+		outsamp := make([]complex64, len(samp))
+		q.mixBlockDown(samp, outsamp)
+		return outsamp
+	})
+}
+
+func Lowpass(cutoffHz float64,
+	sampHz int,
+	decRate int,
+	sigc <-chan []complex64) <-chan []complex64 {
+	return LowpassCtx(context.TODO(), cutoffHz, sampHz, decRate, sigc)
+}
+
+func LowpassCtx(
+	ctx context.Context,
+	cutoffHz float64,
+	sampHz int,
+	decRate int,
+	sigc <-chan []complex64) <-chan []complex64 {
+	As := 70.0
+	cutoffFreq := cutoffHz / float64(sampHz)
+
+	if decRate <= 0 {
+		panic("bad decimation")
+	}
+
+	q := newFIRFilter(firKaiser(64, cutoffFreq, As, 0), float32(2.0*cutoffFreq))
+	return pipe(ctx, sigc, func(samp []complex64) []complex64 { return q.block(samp, decRate) })
+}
+
+func ResampleComplex64(r float32, sigc <-chan []complex64) <-chan []complex64 {
+	return ResampleComplex64Ctx(context.TODO(), r, sigc)
+}
+
+func ResampleComplex64Ctx(ctx context.Context, r float32, sigc <-chan []complex64) <-chan []complex64 {
+	q := newResampler(float64(r))
+	return pipe(ctx, sigc, q.block)
+}
+
+func Resample(r float32, sigc <-chan []float32) <-chan []float32 {
+	q := newResampler(float64(r))
+	return pipe(context.TODO(), sigc, func(samps []float32) []float32 {
+		in := make([]complex64, len(samps))
+		for i, v := range samps {
+			in[i] = complex(v, 0)
+		}
+		out := q.block(in)
+		outsamp := make([]float32, len(out))
+		for i, v := range out {
+			outsamp[i] = real(v)
+		}
+		return outsamp
+	})
+}
+
+func DemodFM(h float32, sigc <-chan []complex64) <-chan []float32 {
+	// h = modulation index = (delta f)/(delta modulation)
+	q := &freqDem{kf: float64(h)}
+	return pipe(context.TODO(), sigc, func(samps []complex64) []float32 {
+		outsamp := make([]float32, len(samps))
+		q.block(samps, outsamp)
+		return outsamp
+	})
+}
+
+func DCBlockerCtx(ctx context.Context, sigc <-chan []complex64) <-chan []complex64 {
+	q := newDCBlocker(0.1)
+	return pipe(ctx, sigc, func(samp []complex64) []complex64 {
+		outsamp := make([]complex64, len(samp))
+		q.block(samp, outsamp)
+		return outsamp
+	})
+}
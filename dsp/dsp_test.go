@@ -0,0 +1,157 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// tone is n samples of a unit complex exponential at hz.
+func tone(hz, fs float64, n int) []complex64 {
+	ret := make([]complex64, n)
+	for i := range ret {
+		ret[i] = complex64(cmplx.Rect(1, 2*math.Pi*hz*float64(i)/fs))
+	}
+	return ret
+}
+
+// stream sends samps in blocks of 1000 and collects the output.
+func stream[I, O any](samps []I, f func(<-chan []I) <-chan []O) (ret []O) {
+	inc := make(chan []I)
+	go func() {
+		defer close(inc)
+		for len(samps) > 0 {
+			n := min(1000, len(samps))
+			inc <- samps[:n]
+			samps = samps[n:]
+		}
+	}()
+	for out := range f(inc) {
+		ret = append(ret, out...)
+	}
+	return ret
+}
+
+// toneHz estimates the frequency of a complex tone from its phase steps.
+func toneHz(samps []complex64, fs float64) float64 {
+	var sum complex128
+	for i := 1; i < len(samps); i++ {
+		sum += complex128(samps[i] * complex(real(samps[i-1]), -imag(samps[i-1])))
+	}
+	return cmplx.Phase(sum) * fs / (2 * math.Pi)
+}
+
+func rms(samps []complex64) float64 {
+	sum := 0.0
+	for _, v := range samps {
+		sum += float64(real(v)*real(v) + imag(v)*imag(v))
+	}
+	return math.Sqrt(sum / float64(len(samps)))
+}
+
+func toComplex(samps []float32) []complex64 {
+	ret := make([]complex64, len(samps))
+	for i, v := range samps {
+		ret[i] = complex(v, 0)
+	}
+	return ret
+}
+
+func TestMixDown(t *testing.T) {
+	const fs = 1e6
+	out := stream(tone(100e3, fs, 10000), func(c <-chan []complex64) <-chan []complex64 {
+		return MixDown(120e3, fs, c)
+	})
+	if len(out) != 10000 {
+		t.Fatalf("got %d samples", len(out))
+	}
+	if hz := toneHz(out, fs); math.Abs(hz+20e3) > 10 {
+		t.Fatalf("tone at %.1fHz, expected -20kHz", hz)
+	}
+	if a := rms(out); math.Abs(a-1) > 0.01 {
+		t.Fatalf("amplitude %.3f", a)
+	}
+}
+
+func TestLowpass(t *testing.T) {
+	const fs = 1e6
+	for _, tt := range []struct {
+		hz   float64
+		gain float64
+	}{{10e3, 1}, {300e3, 0}} {
+		out := stream(tone(tt.hz, fs, 20000), func(c <-chan []complex64) <-chan []complex64 {
+			return Lowpass(50e3, fs, 4, c)
+		})
+		if len(out) != 5000 {
+			t.Fatalf("got %d samples, expected 5000", len(out))
+		}
+		// Skip the filter's start up.
+		if a := rms(out[100:]); math.Abs(a-tt.gain) > 0.01 {
+			t.Errorf("%.0fHz: gain %.4f, expected %.0f", tt.hz, a, tt.gain)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	const fs, hz = 48000.0, 1000.0
+	for _, r := range []float32{0.5, 0.9, 1.5} {
+		out := stream(tone(hz, fs, 48000), func(c <-chan []complex64) <-chan []complex64 {
+			return ResampleComplex64(r, c)
+		})
+		if n := float64(r) * 48000; math.Abs(float64(len(out))-n) > 20 {
+			t.Errorf("%g: got %d samples, expected %.0f", r, len(out), n)
+		}
+		if got := toneHz(out[100:], fs*float64(r)); math.Abs(got-hz) > 1 {
+			t.Errorf("%g: tone at %.2fHz", r, got)
+		}
+		if a := rms(out[100:]); math.Abs(a-1) > 0.02 {
+			t.Errorf("%g: amplitude %.3f", r, a)
+		}
+	}
+
+	sine := make([]float32, 48000)
+	for i := range sine {
+		sine[i] = float32(math.Sin(2 * math.Pi * hz * float64(i) / fs))
+	}
+	out := stream(sine, func(c <-chan []float32) <-chan []float32 { return Resample(0.5, c) })
+	if math.Abs(float64(len(out))-24000) > 20 {
+		t.Fatalf("got %d real samples", len(out))
+	}
+	if a := rms(toComplex(out[100:])); math.Abs(a-math.Sqrt2/2) > 0.02 {
+		t.Fatalf("real amplitude %.3f", a)
+	}
+}
+
+func TestDemodFM(t *testing.T) {
+	const fs, dev, modHz = 240000.0, 5000.0, 1000.0
+	in, ph := make([]complex64, 24000), 0.0
+	for i := range in {
+		in[i] = complex64(cmplx.Rect(0.5, ph))
+		ph += 2 * math.Pi * dev * math.Sin(2*math.Pi*modHz*float64(i)/fs) / fs
+	}
+	out := stream(in, func(c <-chan []complex64) <-chan []float32 { return DemodFM(dev/fs, c) })
+	if len(out) != len(in) {
+		t.Fatalf("got %d samples", len(out))
+	}
+	for i := 1; i < len(out); i++ {
+		expect := math.Sin(2 * math.Pi * modHz * float64(i-1) / fs)
+		if math.Abs(float64(out[i])-expect) > 0.01 {
+			t.Fatalf("sample %d: got %.3f, expected %.3f", i, out[i], expect)
+		}
+	}
+}
+
+func TestDCBlocker(t *testing.T) {
+	in := tone(10e3, 1e6, 10000)
+	for i := range in {
+		in[i] += 0.5 + 0.5i
+	}
+	out := stream(in, func(c <-chan []complex64) <-chan []complex64 { return DCBlockerCtx(t.Context(), c) })
+	var mean complex128
+	for _, v := range out[1000:] {
+		mean += complex128(v)
+	}
+	if m := cmplx.Abs(mean) / float64(len(out)-1000); m > 0.01 {
+		t.Fatalf("dc %.3f left", m)
+	}
+}
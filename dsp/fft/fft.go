@@ -0,0 +1,28 @@
+// Package fft computes forward complex FFTs with fftw or, when built with
+// the purego tag or without cgo, in pure Go.
+package fft
+
+// FFT is a planned forward FFT of a fixed size.
+//
+// Planning is costly, so an FFT should be reused for every transform of
+// its size. An FFT holds working buffers and is not safe for concurrent
+// use; goroutines each need their own. Creating FFTs is safe from any
+// goroutine.
+type FFT interface {
+	// Len is the transform size.
+	Len() int
+	// Transform writes the unnormalized DFT of in to out. Both must be
+	// Len long; they may be the same slice.
+	Transform(out, in []complex64)
+}
+
+// New plans an FFT of n points with the build's preferred backend.
+func New(n int) FFT {
+	if n <= 0 {
+		panic("fft: bad length")
+	}
+	return newDefault(n)
+}
+
+// Backend names the implementation New uses.
+func Backend() string { return backend }
@@ -0,0 +1,69 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func dft(in []complex64) []complex128 {
+	n := len(in)
+	out := make([]complex128, n)
+	for k := range out {
+		for j, v := range in {
+			out[k] += complex128(v) * cmplx.Rect(1, -2*math.Pi*float64(j*k%n)/float64(n))
+		}
+	}
+	return out
+}
+
+func randSamples(rng *rand.Rand, n int) []complex64 {
+	ret := make([]complex64, n)
+	for i := range ret {
+		ret[i] = complex(float32(rng.NormFloat64()), float32(rng.NormFloat64()))
+	}
+	return ret
+}
+
+func TestGo(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8, 12, 30, 97, 100, 128, 1000, 1024} {
+		in := randSamples(rng, n)
+		expect := dft(in)
+		out := make([]complex64, n)
+		NewGo(n).Transform(out, in)
+		for k := range out {
+			// float32 outputs grow with sqrt(n).
+			if d := cmplx.Abs(complex128(out[k]) - expect[k]); d > 1e-4*math.Sqrt(float64(n)) {
+				t.Fatalf("n=%d bin %d: got %v, expected %v", n, k, out[k], expect[k])
+			}
+		}
+	}
+}
+
+func TestBackends(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, n := range []int{16, 1000, 4096} {
+		in := randSamples(rng, n)
+		a, b := make([]complex64, n), make([]complex64, n)
+		f := New(n)
+		if f.Len() != n {
+			t.Fatalf("%s: length %d, expected %d", Backend(), f.Len(), n)
+		}
+		f.Transform(a, in)
+		NewGo(n).Transform(b, in)
+		for k := range a {
+			if d := cmplx.Abs(complex128(a[k] - b[k])); d > 1e-3*math.Sqrt(float64(n)) {
+				t.Fatalf("%s n=%d bin %d: %v vs %v", Backend(), n, k, a[k], b[k])
+			}
+		}
+		// Transforming in place gives the same result.
+		f.Transform(in, in)
+		for k := range a {
+			if in[k] != a[k] {
+				t.Fatalf("%s n=%d: in place transform differs at bin %d", Backend(), n, k)
+			}
+		}
+	}
+}
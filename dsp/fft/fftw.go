@@ -0,0 +1,47 @@
+//go:build cgo && !purego
+
+package fft
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/runningwild/go-fftw/fftw32"
+)
+
+const backend = "fftw"
+
+// planMu serializes fftw's planner, which isn't thread safe. Executing
+// different plans concurrently is.
+var planMu sync.Mutex
+
+type fftwFFT struct {
+	in, out *fftw32.Array
+	plan    *fftw32.Plan
+}
+
+func newDefault(n int) FFT { return NewFFTW(n) }
+
+// NewFFTW plans an FFT with fftw. The plan is destroyed once the FFT is
+// garbage collected.
+func NewFFTW(n int) FFT {
+	planMu.Lock()
+	defer planMu.Unlock()
+	// Plans bind pointers forever, so transforms copy through in and out.
+	in, out := fftw32.NewArray(n), fftw32.NewArray(n)
+	f := &fftwFFT{in: in, out: out, plan: fftw32.NewPlan(in, out, fftw32.Forward, fftw32.DefaultFlag)}
+	runtime.SetFinalizer(f, func(f *fftwFFT) {
+		planMu.Lock()
+		defer planMu.Unlock()
+		f.plan.Destroy()
+	})
+	return f
+}
+
+func (f *fftwFFT) Len() int { return len(f.in.Elems) }
+
+func (f *fftwFFT) Transform(out, in []complex64) {
+	copy(f.in.Elems, in[:len(f.in.Elems)])
+	f.plan.Execute()
+	copy(out[:len(f.out.Elems)], f.out.Elems)
+}
@@ -0,0 +1,95 @@
+package fft
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// goFFT is a mixed radix Cooley-Tukey FFT. Sizes are split into factors of
+// 4, 2, 3, and 5, and any other prime factor is done as a direct DFT.
+type goFFT struct {
+	n       int
+	factors []int
+	// tw[k] is exp(-2*pi*i*k/n).
+	tw  []complex128
+	buf []complex128
+	// tmp holds one butterfly's inputs.
+	tmp []complex128
+}
+
+// NewGo plans an FFT in pure Go, whatever the build's backend.
+func NewGo(n int) FFT {
+	f := &goFFT{n: n, tw: make([]complex128, n), buf: make([]complex128, n)}
+	for k := range f.tw {
+		f.tw[k] = cmplx.Rect(1, -2*math.Pi*float64(k)/float64(n))
+	}
+	maxp := 1
+	for m := n; m > 1; {
+		p := smallestFactor(m)
+		f.factors = append(f.factors, p)
+		maxp = max(maxp, p)
+		m /= p
+	}
+	f.tmp = make([]complex128, maxp)
+	return f
+}
+
+func smallestFactor(n int) int {
+	for _, p := range []int{4, 2, 3, 5} {
+		if n%p == 0 {
+			return p
+		}
+	}
+	for p := 7; p*p <= n; p += 2 {
+		if n%p == 0 {
+			return p
+		}
+	}
+	return n
+}
+
+func (f *goFFT) Len() int { return f.n }
+
+func (f *goFFT) Transform(out, in []complex64) {
+	f.rec(f.buf, in[:f.n], 1, 0)
+	for i, v := range f.buf {
+		out[i] = complex64(v)
+	}
+}
+
+// rec transforms the len(out) samples of in spaced by stride into out
+// using the factors from fi on.
+func (f *goFFT) rec(out []complex128, in []complex64, stride, fi int) {
+	n := len(out)
+	if n == 1 {
+		out[0] = complex128(in[0])
+		return
+	}
+	p := f.factors[fi]
+	m := n / p
+	for j := 0; j < p; j++ {
+		f.rec(out[j*m:(j+1)*m], in[j*stride:], stride*p, fi+1)
+	}
+	// Twiddles of an n point transform are every n/f.n-th of f.tw.
+	step := f.n / n
+	if p == 2 {
+		for k := 0; k < m; k++ {
+			a, b := out[k], out[m+k]*f.tw[k*step]
+			out[k], out[m+k] = a+b, a-b
+		}
+		return
+	}
+	pstep := f.n / p
+	for k := 0; k < m; k++ {
+		for j := 0; j < p; j++ {
+			f.tmp[j] = out[j*m+k] * f.tw[j*k*step]
+		}
+		for q := 0; q < p; q++ {
+			var sum complex128
+			for j := 0; j < p; j++ {
+				sum += f.tmp[j] * f.tw[(j*q%p)*pstep]
+			}
+			out[q*m+k] = sum
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build purego || !cgo
+
+package fft
+
+const backend = "go"
+
+func newDefault(n int) FFT { return NewGo(n) }
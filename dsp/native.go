@@ -0,0 +1,223 @@
+package dsp
+
+import (
+	"context"
+	"math"
+	"math/cmplx"
+)
+
+// Pure Go versions of the liquid-dsp objects the package uses. They keep
+// state between blocks the same way so streams can be split arbitrarily.
+
+// pipe applies f to each block of sigc until sigc closes or ctx is done.
+func pipe[I, O any](ctx context.Context, sigc <-chan []I, f func([]I) []O) <-chan []O {
+	outc := make(chan []O, 1)
+	go func() {
+		defer close(outc)
+		for samp := range sigc {
+			select {
+			case outc <- f(samp):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return outc
+}
+
+// nco mixes blocks down by a fixed frequency.
+type nco struct {
+	phase, step float64
+}
+
+func newNCO(radiansPerSample float64) *nco { return &nco{step: radiansPerSample} }
+
+func (q *nco) mixBlockDown(in, out []complex64) {
+	for i, v := range in {
+		s, c := math.Sincos(q.phase)
+		out[i] = v * complex64(complex(c, -s))
+		if q.phase += q.step; q.phase >= 2*math.Pi {
+			q.phase -= 2 * math.Pi
+		} else if q.phase < 0 {
+			q.phase += 2 * math.Pi
+		}
+	}
+}
+
+// kaiserBeta is the Kaiser window shape for a stopband attenuation in dB.
+func kaiserBeta(as float64) float64 {
+	switch {
+	case as > 50:
+		return 0.1102 * (as - 8.7)
+	case as > 21:
+		return 0.5842*math.Pow(as-21, 0.4) + 0.07886*(as-21)
+	}
+	return 0
+}
+
+// kaiser is the window at t samples from the center of an n sample window.
+func kaiser(t float64, n int, beta float64) float64 {
+	r := 2 * t / float64(n)
+	if r <= -1 || r >= 1 {
+		return 0
+	}
+	return besselI0(beta*math.Sqrt(1-r*r)) / besselI0(beta)
+}
+
+func besselI0(x float64) float64 {
+	s, t := 1.0, 1.0
+	for k := 1; k < 32; k++ {
+		t *= x / 2 / float64(k)
+		s += t * t
+	}
+	return s
+}
+
+func sinc(x float64) float64 {
+	if math.Abs(x) < 1e-9 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// firKaiser designs an n tap lowpass with normalized cutoff fc, like
+// liquid_firdes_kaiser.
+func firKaiser(n int, fc, as, mu float64) []float32 {
+	beta, h := kaiserBeta(as), make([]float32, n)
+	for i := range h {
+		t := float64(i) - float64(n-1)/2 + mu
+		h[i] = float32(sinc(2*fc*t) * kaiser(t, n, beta))
+	}
+	return h
+}
+
+// firFilter is a decimating FIR filter with real taps.
+type firFilter struct {
+	h     []float32
+	scale float32
+	// hist holds the last len(h)-1 inputs.
+	hist []complex64
+	// k counts inputs since the last output.
+	k int
+}
+
+func newFIRFilter(h []float32, scale float32) *firFilter {
+	return &firFilter{h: h, scale: scale, hist: make([]complex64, len(h)-1)}
+}
+
+// block filters in and keeps every dec-th output.
+func (q *firFilter) block(in []complex64, dec int) []complex64 {
+	out := make([]complex64, 0, len(in)/dec)
+	buf := append(q.hist, in...)
+	n := len(q.h)
+	for i := range in {
+		if q.k++; q.k < dec {
+			continue
+		}
+		q.k = 0
+		// buf[i+n-1] is the newest sample.
+		var acc complex64
+		w := buf[i : i+n]
+		for j, tap := range q.h {
+			v := w[n-1-j]
+			acc += complex(real(v)*tap, imag(v)*tap)
+		}
+		out = append(out, complex(real(acc)*q.scale, imag(acc)*q.scale))
+	}
+	q.hist = append(q.hist[:0], buf[len(buf)-(n-1):]...)
+	return out
+}
+
+const (
+	// resampler filter half length in input samples.
+	resampM = 7
+	// resampler filter phases; outputs between phases are interpolated.
+	resampPhases = 64
+	resampAs     = 60
+)
+
+// resampler changes the sample rate by an arbitrary ratio with a
+// polyphase windowed sinc filter, delaying the signal by resampM samples.
+type resampler struct {
+	// step is input samples per output sample.
+	step float64
+	// mu is the next output's offset past the filter center.
+	mu   float64
+	taps [resampPhases + 1][2 * resampM]float32
+	// hist holds the last 2*resampM inputs, newest last.
+	hist []complex64
+}
+
+func newResampler(r float64) *resampler {
+	q := &resampler{step: 1 / r, hist: make([]complex64, 2*resampM)}
+	// Cut off below the lower of the two Nyquist rates.
+	fc := 0.45 * math.Min(1, r)
+	beta := kaiserBeta(resampAs)
+	for p := range q.taps {
+		mu, sum := float64(p)/resampPhases, 0.0
+		var h [2 * resampM]float64
+		for k := range h {
+			t := float64(resampM-k) - mu
+			h[k] = sinc(2*fc*t) * kaiser(t, 2*resampM+1, beta)
+			sum += h[k]
+		}
+		for k := range h {
+			q.taps[p][k] = float32(h[k] / sum)
+		}
+	}
+	return q
+}
+
+func (q *resampler) dot(p int) (acc complex64) {
+	n := len(q.hist)
+	for k, tap := range q.taps[p] {
+		v := q.hist[n-1-k]
+		acc += complex(real(v)*tap, imag(v)*tap)
+	}
+	return acc
+}
+
+func (q *resampler) block(in []complex64) []complex64 {
+	out := make([]complex64, 0, int(math.Ceil(float64(len(in))/q.step))+1)
+	for _, v := range in {
+		q.hist = append(q.hist[1:], v)
+		for ; q.mu < 1; q.mu += q.step {
+			f := q.mu * resampPhases
+			p := int(f)
+			t := float32(f - float64(p))
+			a, b := q.dot(p), q.dot(p+1)
+			out = append(out, a+complex(real(b-a)*t, imag(b-a)*t))
+		}
+		q.mu--
+	}
+	return out
+}
+
+// freqDem demodulates FM with modulation index kf.
+type freqDem struct {
+	kf   float64
+	prev complex64
+}
+
+func (q *freqDem) block(in []complex64, out []float32) {
+	for i, v := range in {
+		d := complex128(v * complex(real(q.prev), -imag(q.prev)))
+		out[i] = float32(cmplx.Phase(d) / (2 * math.Pi * q.kf))
+		q.prev = v
+	}
+}
+
+// dcBlocker is the single pole highpass y[n] = x[n] - x[n-1] + (1-alpha)y[n-1].
+type dcBlocker struct {
+	a      float32
+	x1, y1 complex64
+}
+
+func (q *dcBlocker) block(in, out []complex64) {
+	for i, v := range in {
+		y := v - q.x1 + complex(real(q.y1)*q.a, imag(q.y1)*q.a)
+		q.x1, q.y1, out[i] = v, y, y
+	}
+}
+
+func newDCBlocker(alpha float32) *dcBlocker { return &dcBlocker{a: 1 - alpha} }
@@ -2,11 +2,13 @@ package nicerx
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log"
 
 	"github.com/chzchzchz/nicerx/dsp"
 	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/radio/sigmf"
 	"github.com/chzchzchz/nicerx/store"
 )
 
@@ -39,17 +41,17 @@ func (c *Capture) Step(ctx context.Context) error {
 	// Read windows from SDR and compute FFT.
 	cctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	sampc := c.sdr.Reader().BatchStream64(cctx, windowSamples, 0)
+	batchc := c.sdr.Reader().Subscribe(cctx, windowSamples, 0).C
 	sp := radio.NewSpectralPower(fb, windowSamples, windowSize)
-	readWindow := func() (samps [][]complex64) {
+	readWindow := func() (samps []radio.IQBatch) {
 		windowc, donec := make(chan []complex64, windowSize), make(chan struct{})
 		go func() {
 			defer close(donec)
 			sp.Measure(windowc)
 		}()
-		for samp := range sampc {
-			samps = append(samps, samp)
-			windowc <- samp
+		for b := range batchc {
+			samps = append(samps, b)
+			windowc <- b.Samples
 			if len(samps) >= windowSize {
 				break
 			}
@@ -60,11 +62,11 @@ func (c *Capture) Step(ctx context.Context) error {
 	}
 
 	// Push signal samples to processCapture.
-	var outc chan []complex64
-	var lastSamps [][]complex64
+	var outc chan radio.IQBatch
+	var lastSamps []radio.IQBatch
 	writtenWindows := -1
 	mercy := 1
-	writeWindow := func(oc chan []complex64, samps [][]complex64) {
+	writeWindow := func(oc chan radio.IQBatch, samps []radio.IQBatch) {
 		for _, samp := range samps {
 			oc <- samp
 		}
@@ -75,17 +77,17 @@ func (c *Capture) Step(ctx context.Context) error {
 			return ctx.Err()
 		}
 		samps := readWindow()
-		gain, sdev := sp.BandPower(c.band, sdrRate), sp.Stddev()
-		if gain > sdev && writtenWindows < maxWindowWrite {
+		det, ok := c.detect(sp)
+		if ok && writtenWindows < maxWindowWrite {
 			if outc == nil {
-				outc = make(chan []complex64, windowSize)
+				outc = make(chan radio.IQBatch, windowSize)
 				defer close(outc)
 				go c.processCapture(outc)
 				writeWindow(outc, lastSamps)
 			}
 			mercy = 1
 			writeWindow(outc, samps)
-			log.Printf("mhz: %.3f; gain: %.3f; sdev: %.3f; #%d\n", c.band.Center, gain, sdev, writtenWindows)
+			log.Printf("mhz: %.3f; snr: %.1fdB; width: %.1fkHz; #%d\n", c.band.Center, det.SNR, det.BandwidthKHz(), writtenWindows)
 		} else if outc != nil {
 			writeWindow(outc, samps)
 			if mercy > 0 {
@@ -99,7 +101,18 @@ func (c *Capture) Step(ctx context.Context) error {
 	}
 }
 
-func (c *Capture) processCapture(sampc <-chan []complex64) error {
+// detect finds the strongest signal overlapping the capture band.
+func (c *Capture) detect(sp *radio.SpectralPower) (best radio.Detection, ok bool) {
+	for _, det := range sp.Detect(radio.DefaultCFARConfig) {
+		if det.Overlaps(c.band) && (!ok || det.SNR > best.SNR) {
+			best, ok = det, true
+		}
+	}
+	return best, ok
+}
+
+func (c *Capture) processCapture(batchc <-chan radio.IQBatch) error {
+	sampc := make(chan []complex64, windowSize)
 	mdc := dsp.MixDown(offsetHz, sdrRate, sampc)
 	decRate := 1
 	outHz := sdrRate
@@ -111,17 +124,50 @@ func (c *Capture) processCapture(sampc <-chan []complex64) error {
 	lpc := dsp.Lowpass(c.band.Width*1e6, sdrRate, decRate, mdc)
 
 	outfb := radio.FreqBand{Center: c.band.Center, Width: float64(outHz) / 1e6}
-	outf, err := c.ss.OpenFile(outfb)
+	outf, err := c.ss.OpenFile(outfb, c.sdr.Info())
 	if err != nil {
 		return err
 	}
 	defer outf.Close()
+
+	// Start a new SigMF capture segment after every gap in the samples.
+	var captures []sigmf.Capture
+	var gaps []sigmf.Annotation
+	go func() {
+		defer close(sampc)
+		in := uint64(0)
+		for b := range batchc {
+			out := in / uint64(decRate)
+			if len(captures) == 0 || b.Dropped > 0 {
+				captures = append(captures, sigmf.NewCapture(out, outfb.Center*1e6, b.Time))
+			}
+			if b.Dropped > 0 {
+				gaps = append(gaps, sigmf.Annotation{
+					SampleStart: out,
+					Comment:     fmt.Sprintf("dropped %d samples", b.Dropped),
+				})
+			}
+			in += uint64(len(b.Samples))
+			sampc <- b.Samples
+		}
+	}()
+
 	iqw := radio.NewIQWriter(outf)
 	for samps := range lpc {
 		if err := iqw.Write64(samps); err != nil {
 			return err
 		}
 	}
+	meta, err := sigmf.ReadFile(outf.Name())
+	if err != nil {
+		return err
+	}
+	if len(captures) > 0 {
+		meta.Captures, meta.Annotations = captures, gaps
+	}
+	if err := meta.WriteFile(outf.Name()); err != nil {
+		return err
+	}
 	return WriteSpectrogramFile(outf.Name(), outf.Name()+".jpg", 256)
 }
 
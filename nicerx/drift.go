@@ -0,0 +1,31 @@
+package nicerx
+
+import (
+	"context"
+	"log"
+
+	"github.com/chzchzchz/nicerx/radio"
+)
+
+// DriftTask re-measures the SDR's frequency error between other tasks
+// whenever its monitor is due.
+type DriftTask struct {
+	sdr radio.SDR
+	dm  *radio.DriftMonitor
+}
+
+func NewDriftTask(sdr radio.SDR, dm *radio.DriftMonitor) *DriftTask {
+	return &DriftTask{sdr: sdr, dm: dm}
+}
+
+func (dt *DriftTask) Name() string         { return "drift" }
+func (dt *DriftTask) Band() radio.FreqBand { return radio.FreqBand{} }
+func (dt *DriftTask) Ready() bool          { return dt.dm.Due() }
+
+func (dt *DriftTask) Step(ctx context.Context) error {
+	if _, err := dt.dm.Measure(dt.sdr); err != nil {
+		// A missed measurement shouldn't stop the other tasks.
+		log.Printf("measuring drift: %v", err)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,54 @@
+package nicerx
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	// glyphAdvance leaves a column between characters.
+	glyphAdvance = glyphWidth + 1
+)
+
+// glyphs is a 5x7 bitmap font for axis labels, one row per byte with the
+// leftmost pixel in bit 4.
+var glyphs = map[rune][glyphHeight]uint8{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	':': {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'B': {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'H': {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'M': {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'd': {0x01, 0x01, 0x0d, 0x13, 0x11, 0x11, 0x0f},
+	'z': {0x00, 0x00, 0x1f, 0x02, 0x04, 0x08, 0x1f},
+}
+
+func textWidth(s string) int { return len(s) * glyphAdvance }
+
+// drawText draws s with its top left corner at (x, y). Characters without
+// a glyph are left blank.
+func drawText(img *image.NRGBA, x, y int, s string, c color.NRGBA) {
+	for _, r := range s {
+		g := glyphs[r]
+		for row, bits := range g {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) != 0 {
+					img.SetNRGBA(x+col, y+row, c)
+				}
+			}
+		}
+		x += glyphAdvance
+	}
+}
@@ -0,0 +1,205 @@
+package nicerx
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chzchzchz/nicerx/radio"
+)
+
+// HeatmapConfig sets up a time versus frequency image of sweeps.
+type HeatmapConfig struct {
+	// MinDB and MaxDB are the ends of the color scale; if equal, the scale
+	// covers the data.
+	MinDB, MaxDB float64
+	Colormap     Colormap
+	// Width is the plot width in pixels; 0 is a pixel per bin. Pixels
+	// spanning several bins show the strongest.
+	Width int
+	// RowHeight is the height of each sweep in pixels.
+	RowHeight int
+}
+
+const (
+	heatmapMargin = 4
+	heatmapTick   = 3
+	heatmapBar    = 10
+)
+
+var (
+	heatmapBackground = color.NRGBA{0, 0, 0, 255}
+	heatmapText       = color.NRGBA{255, 255, 255, 255}
+)
+
+// Heatmap renders sweeps top to bottom with frequency and time axes and a
+// dB scale.
+func Heatmap(srs []*radio.SweepResult, cfg HeatmapConfig) (*image.NRGBA, error) {
+	if cfg.Colormap == nil {
+		cfg.Colormap = colorScale
+	}
+	if cfg.RowHeight <= 0 {
+		cfg.RowHeight = 1
+	}
+	loHz, hiHz, binHz := math.Inf(1), math.Inf(-1), math.Inf(1)
+	for _, sr := range srs {
+		for _, seg := range sr.Segments {
+			loHz, hiHz = math.Min(loHz, seg.LoHz), math.Max(hiHz, seg.HiHz())
+			binHz = math.Min(binHz, seg.BinHz)
+		}
+	}
+	if !(hiHz > loHz) {
+		return nil, radio.ErrEmptySweep
+	}
+	w := cfg.Width
+	if w <= 0 {
+		w = max(1, int(math.Round((hiHz-loHz)/binHz)))
+	}
+	pxHz := (hiHz - loHz) / float64(w)
+
+	// Take the strongest bin in each pixel.
+	rows := make([][]float64, len(srs))
+	minDB, maxDB := math.Inf(1), math.Inf(-1)
+	for y, sr := range srs {
+		rows[y] = make([]float64, w)
+		for x := range rows[y] {
+			rows[y][x] = math.NaN()
+		}
+		for _, seg := range sr.Segments {
+			for i, db := range seg.DB {
+				x := int((seg.LoHz + (float64(i)+0.5)*seg.BinHz - loHz) / pxHz)
+				if math.IsNaN(db) || x < 0 || x >= w {
+					continue
+				}
+				if v := rows[y][x]; math.IsNaN(v) || db > v {
+					rows[y][x] = db
+				}
+				minDB, maxDB = math.Min(minDB, db), math.Max(maxDB, db)
+			}
+		}
+	}
+	if cfg.MinDB != cfg.MaxDB {
+		minDB, maxDB = cfg.MinDB, cfg.MaxDB
+	} else if math.IsInf(minDB, 1) {
+		// Every bin is nan.
+		return nil, radio.ErrEmptySweep
+	} else if minDB == maxDB {
+		// Flat data; center it on the scale.
+		minDB, maxDB = minDB-1, maxDB+1
+	}
+
+	timeLabel := "15:04:05"
+	dbLabels := []string{strconv.Itoa(int(math.Round(maxDB))) + "dB", strconv.Itoa(int(math.Round(minDB))) + "dB"}
+	left := heatmapMargin + textWidth(timeLabel) + heatmapTick + 1
+	right := heatmapMargin + heatmapBar + heatmapTick + max(textWidth(dbLabels[0]), textWidth(dbLabels[1])) + heatmapMargin
+	top := heatmapMargin + glyphHeight/2 + 1
+	bottom := heatmapTick + heatmapMargin + glyphHeight + heatmapMargin
+	h := len(srs) * cfg.RowHeight
+	plot := image.Rect(left, top, left+w, top+h)
+	img := image.NewNRGBA(image.Rect(0, 0, plot.Max.X+right, plot.Max.Y+bottom))
+	draw.Draw(img, img.Bounds(), &image.Uniform{heatmapBackground}, image.Point{}, draw.Src)
+
+	scale := 1 / (maxDB - minDB)
+	for y, row := range rows {
+		for x, db := range row {
+			if math.IsNaN(db) {
+				continue
+			}
+			c := cfg.Colormap.Color((db - minDB) * scale)
+			for dy := 0; dy < cfg.RowHeight; dy++ {
+				img.SetNRGBA(plot.Min.X+x, plot.Min.Y+y*cfg.RowHeight+dy, c)
+			}
+		}
+	}
+
+	// Frequency axis, with labels at least a label apart.
+	step, decimals := heatmapFreqStep(pxHz, textWidth("0000.000")+2*glyphAdvance)
+	for k := math.Ceil(loHz / step); k*step <= hiHz; k++ {
+		hz := k * step
+		x := plot.Min.X + int((hz-loHz)/pxHz)
+		for dy := 0; dy < heatmapTick; dy++ {
+			img.SetNRGBA(x, plot.Max.Y+dy, heatmapText)
+		}
+		s := strconv.FormatFloat(hz/1e6, 'f', decimals, 64)
+		drawText(img, x-textWidth(s)/2, plot.Max.Y+heatmapTick+heatmapMargin, s, heatmapText)
+	}
+	drawText(img, heatmapMargin, plot.Max.Y+heatmapTick+heatmapMargin, "MHz", heatmapText)
+
+	// Time axis, with labels at least three lines of text apart.
+	every := (3*glyphHeight + cfg.RowHeight - 1) / cfg.RowHeight
+	for i := 0; i < len(srs); i += every {
+		if len(srs[i].Segments) == 0 {
+			continue
+		}
+		y := plot.Min.Y + i*cfg.RowHeight
+		for dx := 1; dx <= heatmapTick; dx++ {
+			img.SetNRGBA(plot.Min.X-dx, y, heatmapText)
+		}
+		s := srs[i].Segments[0].Time.Format(timeLabel)
+		drawText(img, heatmapMargin, y-glyphHeight/2, s, heatmapText)
+	}
+
+	// dB scale, strongest at the top.
+	barX := plot.Max.X + heatmapMargin
+	for y := plot.Min.Y; y < plot.Max.Y; y++ {
+		c := cfg.Colormap.Color(1 - float64(y-plot.Min.Y)/float64(max(1, h-1)))
+		for dx := 0; dx < heatmapBar; dx++ {
+			img.SetNRGBA(barX+dx, y, c)
+		}
+	}
+	labelX := barX + heatmapBar + heatmapTick
+	drawText(img, labelX, plot.Min.Y-glyphHeight/2, dbLabels[0], heatmapText)
+	drawText(img, labelX, plot.Max.Y-1-glyphHeight/2, dbLabels[1], heatmapText)
+	return img, nil
+}
+
+// heatmapFreqStep picks a 1, 2, or 5 step in Hz at least minPx pixels wide
+// and the decimals needed to label it in MHz.
+func heatmapFreqStep(pxHz float64, minPx int) (float64, int) {
+	step := math.Pow(10, math.Floor(math.Log10(pxHz*float64(minPx))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step*m >= pxHz*float64(minPx) {
+			step *= m
+			break
+		}
+	}
+	decimals := 0
+	if s := strconv.FormatFloat(step/1e6, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	return step, decimals
+}
+
+// WriteHeatmapFile renders an rtl_power CSV as a png, or jpeg for any other
+// extension.
+func WriteHeatmapFile(infn, outfn string, cfg HeatmapConfig) error {
+	inf, err := os.Open(infn)
+	if err != nil {
+		return err
+	}
+	defer inf.Close()
+	srs, err := radio.ReadSweepCSV(inf)
+	if err != nil {
+		return err
+	}
+	img, err := Heatmap(srs, cfg)
+	if err != nil {
+		return err
+	}
+
+	outf, err := os.OpenFile(outfn, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer outf.Close()
+	if strings.HasSuffix(strings.ToLower(outfn), ".png") {
+		return png.Encode(outf, img)
+	}
+	return jpeg.Encode(outf, img, nil)
+}
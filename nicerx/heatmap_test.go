@@ -0,0 +1,59 @@
+package nicerx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chzchzchz/nicerx/radio"
+)
+
+func TestHeatmap(t *testing.T) {
+	// Two passes of one hop; the first rises with frequency, the second
+	// falls.
+	csv := `2024-03-01, 10:00:00, 88000000, 89000000, 250000.00, 100, -40.0, -30.0, -20.0, -10.0
+2024-03-01, 10:00:10, 88000000, 89000000, 250000.00, 100, -10.0, -20.0, -30.0, -40.0
+`
+	srs, err := radio.ReadSweepCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := Colormaps["gray"]
+	img, err := Heatmap(srs, HeatmapConfig{Colormap: cm, RowHeight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := heatmapMargin + textWidth("15:04:05") + heatmapTick + 1
+	top := heatmapMargin + glyphHeight/2 + 1
+	bottom := heatmapTick + heatmapMargin + glyphHeight + heatmapMargin
+	if h := top + 2*2 + bottom; img.Bounds().Dy() != h {
+		t.Fatalf("expected height %d, got %d", h, img.Bounds().Dy())
+	}
+	if w := img.Bounds().Dx(); w < left+4 {
+		t.Fatalf("image %d wide, expected more than %d", w, left+4)
+	}
+	// Bins go left to right, passes top to bottom.
+	for _, tt := range []struct {
+		x, y int
+		v    float64
+	}{{0, 0, 0}, {3, 0, 1}, {0, 3, 1}, {3, 3, 0}} {
+		if c := img.NRGBAAt(left+tt.x, top+tt.y); c != cm.Color(tt.v) {
+			t.Fatalf("pixel %d,%d: got %v, expected %v", tt.x, tt.y, c, cm.Color(tt.v))
+		}
+	}
+
+	// A flat sweep is plotted rather than dividing by zero.
+	flat := `2024-03-01, 10:00:00, 88000000, 89000000, 250000.00, 100, -10.0, -10.0, -10.0, -10.0
+`
+	if srs, err = radio.ReadSweepCSV(strings.NewReader(flat)); err != nil {
+		t.Fatal(err)
+	}
+	if img, err = Heatmap(srs, HeatmapConfig{Colormap: cm}); err != nil {
+		t.Fatal(err)
+	}
+	if c := img.NRGBAAt(left, top); c != cm.Color(0.5) {
+		t.Fatalf("flat sweep: got %v, expected %v", c, cm.Color(0.5))
+	}
+	if _, err := Heatmap(nil, HeatmapConfig{}); err != radio.ErrEmptySweep {
+		t.Fatalf("expected empty sweep error, got %v", err)
+	}
+}
@@ -43,10 +43,15 @@ table, th, td {
 <hr/>
 
 <h2>SDR status &#x1F4FB;</h2>
+{{with .SDRInfo}}
 <ul>
-<li>Current frequency: {{printf "%.3f" .SDR.Band.BeginMHz}}--{{printf "%.3f" .SDR.Band.EndMHz}}MHz</li>
-<li>Frequency correction: {{.SDR.PPM}}ppm</li>
+<li>Radio: {{.Id}}</li>
+<li>Current frequency: {{.CenterHz}}Hz @ {{.SampleRate}}sps</li>
+<li>Frequency correction: {{.PPM}}ppm</li>
+{{with .Drift}}<li>Drift: {{range .}}{{printf "%.2f" .PPM}} {{end}}ppm</li>{{end}}
+<li>Gain: {{if .Gain.TunerAGC}}tuner AGC{{else}}{{printf "%.1f" .Gain.DB}}dB{{end}}{{if .Gain.AGC}}, RTL AGC{{end}}{{if .Gain.BiasTee}}, bias tee on{{end}}</li>
 </ul>
+{{end}}
 
 <h2>Scheduled tasks &#x1F552;</h2>
 <table>
@@ -43,15 +43,50 @@ func (s *sdrHandler) handleTune(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *sdrHandler) handleGain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	defer func() {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+	if err != nil {
+		return
+	}
+	var g radio.GainConfig
+	if err = json.Unmarshal(b, &g); err != nil {
+		return
+	}
+	if err = s.s.SDR.SetGain(g); err != nil {
+		return
+	}
+}
+
 func (s *sdrHandler) handleRaw(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
+	format, err := radio.ParseSampleFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bp, err := radio.ParseBackpressure(r.URL.Query().Get("backpressure"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	w.Header().Set("Content-Type", "binary/octet-stream")
 	ctx, cancel := context.WithCancel(context.TODO())
 	defer cancel()
-	iqw := radio.NewIQWriter(w)
-	for samps := range s.s.SDR.Reader().BatchStream64(ctx, 2048, 0) {
+	iqw := radio.NewIQWriterFormat(w, format)
+	cfg := radio.StreamConfig{Batch: 2048, Backpressure: bp}
+	for samps := range s.s.SDR.Reader().BatchStreamConfig64(ctx, cfg) {
 		if err := iqw.Write64(samps); err != nil {
 			return
 		}
@@ -64,7 +99,7 @@ func (s *sdrHandler) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	js, err := json.Marshal([]radio.SDRHWInfo{s.s.SDR.Info()})
+	js, err := json.Marshal([]radio.SDRHWInfo{s.s.SDRInfo()})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -76,6 +111,7 @@ func newSDRHandler(s *nicerx.Server) http.Handler {
 	sh := sdrHandler{s}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/tune", sh.handleTune)
+	mux.HandleFunc("/gain", sh.handleGain)
 	mux.HandleFunc("/raw", sh.handleRaw)
 	mux.HandleFunc("/", sh.handleIndex)
 	return mux
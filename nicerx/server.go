@@ -15,6 +15,10 @@ type Server struct {
 	Bands   *store.BandStore
 	Tasks   *TaskQueue
 	Signals *store.SignalStore
+	// Drift tracks the SDR's frequency error, if monitored.
+	Drift *radio.DriftMonitor
+	// runTasks is set once a background task needs the queue running.
+	runTasks bool
 
 	rxers map[string]*receiver.Rxer
 
@@ -39,11 +43,40 @@ func NewServer(sdr radio.SDR) (*Server, error) {
 	return s, nil
 }
 
+// Serve runs the task queue if drift monitoring or a sweep was scheduled.
 func (s *Server) Serve(ctx context.Context) error {
+	s.mu.RLock()
+	runTasks := s.runTasks
+	s.mu.RUnlock()
+	if runTasks {
+		if err := s.Tasks.Run(ctx); err != nil {
+			return err
+		}
+	}
 	<-ctx.Done()
 	return ctx.Err()
 }
 
+// MonitorDrift schedules drift measurements ahead of the other tasks. An
+// SDR with a pinned correction is not monitored.
+func (s *Server) MonitorDrift(cfg radio.DriftConfig, cal *radio.CalibrationTable) {
+	if p := s.SDR.Info().Profile; p != nil && p.PPM != nil {
+		return
+	}
+	s.Drift = radio.NewDriftMonitor(cfg, cal)
+	s.Tasks.Prioritize(s.Tasks.Add(NewDriftTask(s.SDR, s.Drift)), 10)
+	s.startTasks()
+}
+
+// SDRInfo is the SDR's status and drift history.
+func (s *Server) SDRInfo() radio.SDRHWInfo {
+	info := s.SDR.Info()
+	if s.Drift != nil {
+		info.Drift = s.Drift.History()
+	}
+	return info
+}
+
 func (s *Server) Rxers() []receiver.RxConfigBase {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -85,6 +118,27 @@ func (s *Server) Capture(centerMHz float64) {
 	s.Tasks.Prioritize(tid, 2)
 }
 
+// sweepPriority runs sweeps only when other tasks have nothing to do.
+const sweepPriority = -10
+
+// Sweep schedules passes of a sweep written as CSV to w; 0 repeats forever.
+func (s *Server) Sweep(cfg radio.SweepConfig, w io.Writer, passes int) error {
+	st, err := NewSweepTask(s.SDR, cfg, w)
+	if err != nil {
+		return err
+	}
+	st.Passes = passes
+	s.Tasks.Prioritize(s.Tasks.Add(st), sweepPriority)
+	s.startTasks()
+	return nil
+}
+
+func (s *Server) startTasks() {
+	s.mu.Lock()
+	s.runTasks = true
+	s.mu.Unlock()
+}
+
 type SignalBand struct {
 	store.BandRecord
 	HasSignal  bool
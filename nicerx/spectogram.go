@@ -1,34 +1,74 @@
 package nicerx
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"math/cmplx"
 	"os"
+	"strings"
 
+	"github.com/chzchzchz/nicerx/dsp/fft"
 	"github.com/chzchzchz/nicerx/radio"
-
-	"github.com/runningwild/go-fftw/fftw32"
 )
 
-// black, green, yellow, white
-var colorScale = []color.NRGBA{
-	{0, 0, 0, 255},
-	{0, 255, 0, 255},
-	{255, 255, 0, 255},
-	{255, 255, 255, 255},
+// Colormap maps [0, 1] to colors by interpolating evenly spaced stops.
+type Colormap []color.NRGBA
+
+// Colormaps are the named colormaps; "default" is black, green, yellow, white.
+var Colormaps = map[string]Colormap{
+	"default": {
+		{0, 0, 0, 255},
+		{0, 255, 0, 255},
+		{255, 255, 0, 255},
+		{255, 255, 255, 255},
+	},
+	"gray": {
+		{0, 0, 0, 255},
+		{255, 255, 255, 255},
+	},
+	"viridis": {
+		{68, 1, 84, 255},
+		{59, 82, 139, 255},
+		{33, 145, 140, 255},
+		{94, 201, 98, 255},
+		{253, 231, 37, 255},
+	},
+	"inferno": {
+		{0, 0, 4, 255},
+		{87, 16, 110, 255},
+		{188, 55, 84, 255},
+		{249, 142, 9, 255},
+		{252, 255, 164, 255},
+	},
 }
 
+func ParseColormap(s string) (Colormap, error) {
+	if s == "" {
+		s = "default"
+	}
+	cm, ok := Colormaps[strings.ToLower(s)]
+	if !ok {
+		return nil, fmt.Errorf("unknown colormap %q", s)
+	}
+	return cm, nil
+}
+
+var colorScale = Colormaps["default"]
+
 func interpolate(t float64, a, b uint8) uint8 { return uint8(float64(a)*(1-t) + float64(b)*t) }
 
-func FFTBin2Color(v float64) color.NRGBA {
-	idx := float64(len(colorScale)-1) * v
-	if int(idx)+1 >= len(colorScale) {
-		panic("bad idx")
+// Color is the color of v, clamped to [0, 1].
+func (cm Colormap) Color(v float64) color.NRGBA {
+	if !(v > 0) {
+		return cm[0]
+	} else if v >= 1 {
+		return cm[len(cm)-1]
 	}
+	idx := float64(len(cm)-1) * v
 	t := idx - float64(int(idx))
-	prev, next := colorScale[int(idx)], colorScale[int(idx)+1]
+	prev, next := cm[int(idx)], cm[int(idx)+1]
 	return color.NRGBA{
 		interpolate(t, prev.R, next.R),
 		interpolate(t, prev.G, next.G),
@@ -37,22 +77,24 @@ func FFTBin2Color(v float64) color.NRGBA {
 	}
 }
 
+func FFTBin2Color(v float64) color.NRGBA { return colorScale.Color(v) }
+
 type spectrogram struct {
-	plan    *fftw32.Plan
-	in, out *fftw32.Array
+	plan    fft.FFT
+	in, out []complex64
 	inc     <-chan []complex64
 	outc    chan<- []float64
 }
 
 func (sp *spectrogram) run() {
-	bins := len(sp.out.Elems)
+	bins := len(sp.out)
 	for samps := range sp.inc {
-		copy(sp.in.Elems, samps)
-		sp.plan.Execute()
+		copy(sp.in, samps)
+		sp.plan.Transform(sp.out, sp.in)
 
 		fft := make([]float64, len(samps))
 		min, max := 0.0, 0.0
-		for i, v := range sp.out.Elems[1:] {
+		for i, v := range sp.out[1:] {
 			j := i + 1
 			fft[j] = cmplx.Abs(complex128(v))
 			if fft[j] < min {
@@ -82,13 +124,10 @@ func SpectrogramChan(inc <-chan []complex64, bins int) <-chan []float64 {
 	outc := make(chan []float64, 2)
 	go func() {
 		defer close(outc)
-		in, out := fftw32.NewArray(bins), fftw32.NewArray(bins)
-		plan := fftw32.NewPlan(in, out, fftw32.Forward, fftw32.DefaultFlag)
-		defer plan.Destroy()
 		sp := spectrogram{
-			plan: plan,
-			in:   in,
-			out:  out,
+			plan: fft.New(bins),
+			in:   make([]complex64, bins),
+			out:  make([]complex64, bins),
 			inc:  inc,
 			outc: outc,
 		}
@@ -0,0 +1,61 @@
+package nicerx
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/chzchzchz/nicerx/radio"
+)
+
+// SweepTask sweeps a range one hop per step, writing each hop as an
+// rtl_power CSV row. Sweeps repeat until Passes have been written.
+type SweepTask struct {
+	sdr radio.SDR
+	sw  *radio.Sweeper
+	w   io.Writer
+
+	// Passes is the number of sweeps before stopping; 0 sweeps forever.
+	Passes int
+
+	hop  int
+	pass int
+}
+
+func NewSweepTask(sdr radio.SDR, cfg radio.SweepConfig, w io.Writer) (*SweepTask, error) {
+	sw, err := radio.NewSweeper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &SweepTask{sdr: sdr, sw: sw, w: w, Passes: 1}, nil
+}
+
+func (st *SweepTask) Name() string         { return "sweep" }
+func (st *SweepTask) Band() radio.FreqBand { return st.sw.Hop(st.hop).ToMHz() }
+
+func (st *SweepTask) Step(ctx context.Context) error {
+	if st.Passes > 0 && st.pass >= st.Passes {
+		return io.EOF
+	}
+	defer st.next()
+	seg, err := st.sw.Measure(ctx, st.sdr, st.hop)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Leave a gap in the pass rather than give up on the sweep.
+		log.Printf("sweeping %dHz: %v", st.sw.Hop(st.hop).Center, err)
+		return nil
+	}
+	if err := seg.WriteCSV(st.w); err != nil {
+		log.Printf("writing sweep: %v", err)
+		return io.EOF
+	}
+	return nil
+}
+
+func (st *SweepTask) next() {
+	if st.hop++; st.hop == st.sw.Hops() {
+		st.hop, st.pass = 0, st.pass+1
+	}
+}
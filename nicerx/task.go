@@ -3,6 +3,7 @@ package nicerx
 import (
 	"context"
 	"io"
+	"log"
 	"sync"
 	"time"
 
@@ -30,6 +31,11 @@ func (it *idleTask) Band() radio.FreqBand { return radio.FreqBand{} }
 
 func newIdleTask() Task { return &idleTask{} }
 
+// readyTask is a task that only sometimes has work to do.
+type readyTask interface {
+	Ready() bool
+}
+
 type TaskId int
 
 type ScheduledTask struct {
@@ -39,7 +45,32 @@ type ScheduledTask struct {
 	startTime     time.Time
 	stopTime      time.Time
 	totalDuration time.Duration
-	mu            sync.RWMutex
+	// failures counts consecutive failed steps; the task waits until
+	// retryTime before stepping again.
+	failures  int
+	retryTime time.Time
+	mu        sync.RWMutex
+}
+
+// maxRetryWait caps the backoff of a failing task.
+const maxRetryWait = time.Minute
+
+// failed backs off the task after a failed step.
+func (st *ScheduledTask) failed() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	wait := maxRetryWait
+	if st.failures < 6 {
+		wait = time.Second << st.failures
+	}
+	st.failures++
+	st.retryTime = st.stopTime.Add(wait)
+}
+
+func (st *ScheduledTask) waiting() bool {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return time.Now().Before(st.retryTime)
 }
 
 func (st ScheduledTask) Duration() time.Duration {
@@ -97,9 +128,14 @@ func (tq *TaskQueue) Run(ctx context.Context) error {
 		t.mu.Lock()
 		t.stopTime = time.Now()
 		t.totalDuration += t.stopTime.Sub(t.startTime)
+		if err == nil {
+			t.failures = 0
+		}
 		t.mu.Unlock()
-		if err != nil {
-			return err
+		if err != nil && ctx.Err() == nil {
+			// A failed step shouldn't stop the other tasks.
+			log.Printf("task %s: %v", t.Name(), err)
+			t.failed()
 		}
 	}
 	return nil
@@ -110,6 +146,12 @@ func (tq *TaskQueue) nextTask() *ScheduledTask {
 	tq.mu.RLock()
 	defer tq.mu.RUnlock()
 	for _, t := range tq.Running {
+		if rt, ok := t.Task.(readyTask); ok && !rt.Ready() {
+			continue
+		}
+		if t.waiting() {
+			continue
+		}
 		if bestTask == nil {
 			bestTask = t
 		} else if bestTask.Priority < t.Priority {
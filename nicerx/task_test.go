@@ -0,0 +1,47 @@
+package nicerx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/chzchzchz/nicerx/radio"
+)
+
+// countTask counts its steps, returning err from each.
+type countTask struct {
+	steps int
+	err   error
+}
+
+func (ct *countTask) Name() string { return "count" }
+func (ct *countTask) Step(ctx context.Context) error {
+	ct.steps++
+	return ct.err
+}
+func (ct *countTask) Band() radio.FreqBand { return radio.FreqBand{} }
+
+func TestTaskFailing(t *testing.T) {
+	tq := NewTaskQueue()
+	failing := &countTask{err: errors.New("always fails")}
+	tq.Prioritize(tq.Add(failing), 10)
+	lower := &countTask{err: io.EOF}
+	tq.Add(lower)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 500*time.Millisecond)
+	defer cancel()
+	if err := tq.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if failing.steps != 1 {
+		t.Fatalf("expected failing task to back off after 1 step, got %d", failing.steps)
+	}
+	if lower.steps != 1 {
+		t.Fatalf("expected lower priority task to run, got %d steps", lower.steps)
+	}
+	if len(tq.Running) != 2 {
+		t.Fatalf("expected failing and idle tasks left running, got %d", len(tq.Running))
+	}
+}
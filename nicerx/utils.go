@@ -8,8 +8,10 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/radio/sigmf"
 	"github.com/chzchzchz/nicerx/radio/wav"
 	"github.com/chzchzchz/nicerx/sdrproxy"
 	"github.com/chzchzchz/nicerx/sdrproxy/client"
@@ -35,26 +37,54 @@ func OpenOutputS16(path string, hzb radio.HzBand) (io.Writer, func(), error) {
 }
 
 func OpenIQW(path string, hzb radio.HzBand) (*radio.IQWriter, func(), error) {
+	if sigmf.IsSigMF(path) && !isStdio(path) {
+		return openSigMFW(path, hzb)
+	}
 	w, closer, err := openOutput(path)
 	if err != nil {
 		return nil, nil, err
 	}
 	if strings.HasSuffix(path, ".wav") {
-		ww, err := wav.NewWriter(w, int(hzb.Width), 8, 2)
+		// The format comes from the extension before .wav (e.g., "x.cs16.wav").
+		format := radio.PathSampleFormat(strings.TrimSuffix(path, ".wav"))
+		ww, err := radio.NewWavIQWriter(w, format, hzb, time.Now())
 		if err != nil {
+			closer()
 			return nil, nil, err
 		}
 		wavCloser := func() {
 			ww.Close()
 			closer()
 		}
-		return radio.NewIQWriter(w), wavCloser, nil
+		return radio.NewIQWriterFormat(ww, format), wavCloser, nil
+	}
+	return radio.NewIQWriterFormat(w, radio.PathSampleFormat(path)), closer, nil
+}
+
+// openSigMFW writes a SigMF recording; the format comes from the extension
+// before .sigmf-data (e.g., "x.cs16.sigmf-data"), defaulting to cu8.
+func openSigMFW(path string, hzb radio.HzBand) (*radio.IQWriter, func(), error) {
+	path = sigmf.DataPath(path)
+	format := radio.PathSampleFormat(strings.TrimSuffix(path, sigmf.DataExt))
+	w, closer, err := openOutput(path)
+	if err != nil {
+		return nil, nil, err
 	}
-	return radio.NewIQWriter(w), closer, nil
+	if err := radio.NewSigMFMeta(format, hzb, time.Now(), nil).WriteFile(path); err != nil {
+		closer()
+		return nil, nil, err
+	}
+	return radio.NewIQWriterFormat(w, format), closer, nil
+}
+
+// isStdio checks if the path is "-", optionally with an extension for the
+// format (e.g., "-.wav", "-.cs16").
+func isStdio(path string) bool {
+	return path == "-" || strings.HasPrefix(path, "-.")
 }
 
 func openOutput(path string) (io.Writer, func(), error) {
-	if path == "-" || path == "-.wav" || path == "-.iq8" {
+	if isStdio(path) {
 		return os.Stdout, func() {}, nil
 	}
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
@@ -70,6 +100,18 @@ func OpenIQR(path string, hzb radio.HzBand) (*radio.MixerIQReader, func(), error
 			return openIQRURL(*u, hzb)
 		}
 	}
+	if sigmf.IsSigMF(path) && !isStdio(path) {
+		// Metadata has the tuning and format.
+		b, format, err := radio.ReadSigMF(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		f, closer, err := openInput(sigmf.DataPath(path))
+		if err != nil {
+			return nil, nil, err
+		}
+		return radio.NewIQReaderFormat(f, format).ToMixer(b), closer, nil
+	}
 	f, closer, err := openInput(path)
 	if err != nil {
 		return nil, nil, err
@@ -79,14 +121,21 @@ func OpenIQR(path string, hzb radio.HzBand) (*radio.MixerIQReader, func(), error
 		if err != nil {
 			return nil, nil, err
 		}
-		hzb = radio.HzBand{Center: 0, Width: uint64(r.SampleRate())}
-		return radio.NewMixerIQReader(r, hzb), closer, nil
+		b, format, err := radio.WavTuning(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		if b.Center == 0 {
+			b.Center = hzb.Center
+		}
+		return radio.NewIQReaderFormat(r, format).ToMixer(b), closer, nil
 	}
-	return radio.NewMixerIQReader(f, hzb), closer, nil
+	iqr := radio.NewIQReaderFormat(f, radio.PathSampleFormat(path))
+	return iqr.ToMixer(hzb), closer, nil
 }
 
 func openInput(path string) (io.Reader, func(), error) {
-	if path == "-" || path == "-.wav" || path == "-.iq8" {
+	if isStdio(path) {
 		return os.Stdin, func() {}, nil
 	}
 	fin, err := os.Open(path)
@@ -113,7 +162,24 @@ func openIQRURL(u url.URL, b radio.HzBand) (*radio.MixerIQReader, func(), error)
 	if sdrDevice == "" {
 		return nil, nil, fmt.Errorf("no sdr device defined in url %s", u.String())
 	}
-	u.Path, u.Scheme = "", "http"
+	// sdr://host/device?format=cs16&backpressure=block
+	format, err := radio.ParseSampleFormat(u.Query().Get("format"))
+	if err != nil {
+		return nil, nil, err
+	}
+	bp, err := radio.ParseBackpressure(u.Query().Get("backpressure"))
+	if err != nil {
+		return nil, nil, err
+	}
+	u.Path, u.Scheme, u.RawQuery = "", "http", ""
+	name := sdrDevice
+	// Signals by the same name must have the same format and policy.
+	if format != radio.FormatCU8 {
+		name += "-" + string(format)
+	}
+	if bp != radio.BackpressureDisconnect {
+		name += "-" + string(bp)
+	}
 	c := client.New(u)
 	log.Printf("opening %s and connected to %s", sdrDevice, u.String())
 	cctx, cancel := context.WithCancel(context.Background())
@@ -132,9 +198,11 @@ func openIQRURL(u url.URL, b radio.HzBand) (*radio.MixerIQReader, func(), error)
 			if sig.Response.Radio.Id == sdrDevice {
 				log.Printf("got radio %+v", sig.Response.Radio)
 				req := sdrproxy.RxRequest{
-					HzBand: sig.Response.Radio.HzBand(),
-					Name:   sdrDevice,
-					Radio:  sdrDevice,
+					HzBand:       sig.Response.Radio.HzBand(),
+					Name:         name,
+					Radio:        sdrDevice,
+					Format:       format,
+					Backpressure: bp,
 				}
 				iqr, err := c.OpenIQReader(cctx, req)
 				if err != nil {
@@ -146,11 +214,12 @@ func openIQRURL(u url.URL, b radio.HzBand) (*radio.MixerIQReader, func(), error)
 		return nil, nil, fmt.Errorf("could not find sdr")
 	}
 
-	name := fmt.Sprintf("%s-%d", sdrDevice, b.Center)
 	req := sdrproxy.RxRequest{
-		HzBand: b,
-		Name:   name,
-		Radio:  sdrDevice,
+		HzBand:       b,
+		Name:         fmt.Sprintf("%s-%d", name, b.Center),
+		Radio:        sdrDevice,
+		Format:       format,
+		Backpressure: bp,
 	}
 	iqr, err := c.OpenIQReader(cctx, req)
 	if err != nil {
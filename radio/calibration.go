@@ -0,0 +1,108 @@
+package radio
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Calibration is a measured crystal error for a device.
+type Calibration struct {
+	// PPM is the fractional error; SDRs apply the nearest whole ppm.
+	PPM       float64      `json:"ppm"`
+	Reference CalReference `json:"reference"`
+	Time      time.Time    `json:"time"`
+}
+
+// CalibrationTable persists calibrations keyed by device serial.
+type CalibrationTable struct {
+	path string
+	devs map[string]Calibration
+	mu   sync.RWMutex
+}
+
+// DefaultCalibrationPath is calibration.json in the user's nicerx config
+// directory.
+func DefaultCalibrationPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "calibration.json"
+	}
+	return filepath.Join(dir, "nicerx", "calibration.json")
+}
+
+// LoadCalibrationTable reads the table at path; a missing file is empty.
+func LoadCalibrationTable(path string) (*CalibrationTable, error) {
+	t := &CalibrationTable{path: path, devs: make(map[string]Calibration)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &t.devs); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *CalibrationTable) Get(serial string) (Calibration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c, ok := t.devs[serial]
+	return c, ok
+}
+
+// Set records a calibration and saves the table.
+func (t *CalibrationTable) Set(serial string, c Calibration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.devs[serial] = c
+	b, err := json.MarshalIndent(t.devs, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	// Replace the file in one step so a crash can't truncate it.
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Apply sets the SDR's frequency correction from the table, if it has one.
+func (t *CalibrationTable) Apply(sdr SDR) error {
+	id := sdr.Info().Id
+	c, ok := t.Get(id)
+	if !ok {
+		return nil
+	}
+	log.Printf("applying %.2fppm calibration to %s", c.PPM, id)
+	return sdr.SetFreqCorrection(uint32(int32(math.Round(c.PPM))))
+}
+
+// Calibrate measures the SDR against the reference, records the result,
+// and restores the SDR's tuning.
+func (t *CalibrationTable) Calibrate(sdr SDR, ref CalReference) (Calibration, error) {
+	info := sdr.Info()
+	ppm, err := CalibrateRef(sdr, ref)
+	if info.SampleRate != 0 {
+		if err := sdr.SetBand(info.HzBand()); err != nil {
+			return Calibration{}, err
+		}
+	}
+	if err != nil {
+		return Calibration{}, err
+	}
+	c := Calibration{PPM: ppm, Reference: ref, Time: time.Now()}
+	return c, t.Set(info.Id, c)
+}
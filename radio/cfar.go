@@ -0,0 +1,323 @@
+package radio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CFARMethod is how a CFAR detector estimates the noise around a cell.
+type CFARMethod string
+
+const (
+	// CFARCellAverage averages the training cells. It is optimal in flat
+	// noise but strong signals in the training cells mask weak ones.
+	CFARCellAverage CFARMethod = "ca"
+	// CFAROrderStatistic takes a rank of the sorted training cells,
+	// ignoring signals in the training cells.
+	CFAROrderStatistic CFARMethod = "os"
+)
+
+func ParseCFARMethod(s string) (CFARMethod, error) {
+	switch m := CFARMethod(strings.ToLower(s)); m {
+	case "":
+		return CFAROrderStatistic, nil
+	case CFARCellAverage, CFAROrderStatistic:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown cfar method %q", s)
+}
+
+// CFARConfig configures a constant false alarm rate detector.
+type CFARConfig struct {
+	Method CFARMethod
+	// Guard is the number of cells skipped on each side of the cell under
+	// test so a signal's skirts don't count as noise.
+	Guard int
+	// Train is the number of cells on each side used to estimate noise.
+	Train int
+	// Pfa is the probability a noise cell is detected as a signal.
+	Pfa float64
+	// Rank is the order statistic, as a fraction of the training cells.
+	Rank float64
+}
+
+var DefaultCFARConfig = CFARConfig{
+	Method: CFAROrderStatistic,
+	Guard:  4,
+	Train:  64,
+	Pfa:    1e-6,
+	Rank:   0.5,
+}
+
+func (cfg CFARConfig) withDefaults() CFARConfig {
+	d := DefaultCFARConfig
+	if cfg.Method == "" {
+		cfg.Method = d.Method
+	}
+	if cfg.Guard <= 0 {
+		cfg.Guard = d.Guard
+	}
+	if cfg.Train <= 0 {
+		cfg.Train = d.Train
+	}
+	if cfg.Pfa <= 0 || cfg.Pfa >= 1 {
+		cfg.Pfa = d.Pfa
+	}
+	if cfg.Rank <= 0 || cfg.Rank > 1 {
+		cfg.Rank = d.Rank
+	}
+	return cfg
+}
+
+// Detection is a signal found by CFAR.
+type Detection struct {
+	// FreqBand is the signal's occupied bandwidth.
+	FreqBand
+	// SNR is the peak power over the noise estimate, in dB.
+	SNR float64
+	// PeakDB is the peak power.
+	PeakDB float64
+}
+
+// cfarDetection is a run of detected cells.
+type cfarDetection struct {
+	binBand
+	snr float64
+}
+
+// cfar detects cells of a power spectrum, in dB, that stand out from their
+// neighbors. Adjacent detected cells are grouped into one detection. Each
+// cell is an average of looks independent power measurements.
+func cfar(pwrDB []float64, cfg CFARConfig, looks float64) (ret []cfarDetection) {
+	cfg = cfg.withDefaults()
+	lin := make([]float64, len(pwrDB))
+	for i, db := range pwrDB {
+		lin[i] = math.Pow(10, db/10)
+	}
+	scales := make(map[int]float64)
+	scale := func(n int) float64 {
+		if _, ok := scales[n]; !ok {
+			scales[n] = cfarScale(cfg, n, looks)
+		}
+		return scales[n]
+	}
+	cells := make([]float64, 0, 2*cfg.Train)
+	var cur *cfarDetection
+	for i := range lin {
+		cells = cells[:0]
+		for j := i - cfg.Guard - cfg.Train; j < i-cfg.Guard; j++ {
+			if j >= 0 {
+				cells = append(cells, lin[j])
+			}
+		}
+		for j := i + cfg.Guard + 1; j <= i+cfg.Guard+cfg.Train; j++ {
+			if j < len(lin) {
+				cells = append(cells, lin[j])
+			}
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		noise := cfarNoise(cfg, cells)
+		if lin[i] <= noise*scale(len(cells)) {
+			cur = nil
+			continue
+		}
+		snr := pwrDB[i] - powerDB(noise)
+		if cur == nil {
+			ret = append(ret, cfarDetection{binBand{Begin: i, DB: pwrDB[i]}, snr})
+			cur = &ret[len(ret)-1]
+		}
+		cur.Bins++
+		if pwrDB[i] > cur.DB {
+			cur.DB, cur.snr = pwrDB[i], snr
+		}
+	}
+	return ret
+}
+
+func cfarNoise(cfg CFARConfig, cells []float64) float64 {
+	if cfg.Method == CFARCellAverage {
+		sum := 0.0
+		for _, v := range cells {
+			sum += v
+		}
+		return sum / float64(len(cells))
+	}
+	sort.Float64s(cells)
+	return cells[cfarRank(cfg, len(cells))]
+}
+
+func cfarRank(cfg CFARConfig, n int) int {
+	return min(n-1, max(0, int(math.Ceil(cfg.Rank*float64(n)))-1))
+}
+
+type cfarScaleKey struct {
+	cfg   CFARConfig
+	n     int
+	looks float64
+}
+
+// cfarScales caches thresholds, which take a while to find for averages.
+var cfarScales sync.Map
+
+// cfarScale is the threshold over the noise estimate of n cells giving the
+// false alarm rate. Noise power averaged over looks measurements is gamma
+// distributed; a single measurement's is exponential.
+func cfarScale(cfg CFARConfig, n int, looks float64) float64 {
+	if looks <= 1 {
+		return cfarScaleExp(cfg, n)
+	}
+	key := cfarScaleKey{cfg, n, looks}
+	if v, ok := cfarScales.Load(key); ok {
+		return v.(float64)
+	}
+	var pfa func(t float64) float64
+	if cfg.Method == CFARCellAverage {
+		// The sum of the cells has shape n*looks.
+		sumLooks := float64(n) * looks
+		pfa = func(t float64) float64 {
+			return integrate(func(s float64) float64 {
+				return gammaQ(looks, t*s/float64(n)) * gammaPDF(sumLooks, s)
+			}, gammaSpan(sumLooks))
+		}
+	} else {
+		k := cfarRank(cfg, n) + 1
+		lnC := lgamma(float64(n+1)) - lgamma(float64(k)) - lgamma(float64(n-k+1))
+		pfa = func(t float64) float64 {
+			// Weigh by the density of the k-th smallest of n cells.
+			return integrate(func(y float64) float64 {
+				q := gammaQ(looks, y)
+				if q <= 0 || q >= 1 {
+					return 0
+				}
+				lnF := float64(k-1)*math.Log(1-q) + float64(n-k)*math.Log(q)
+				return gammaQ(looks, t*y) * math.Exp(lnC+lnF) * gammaPDF(looks, y)
+			}, gammaSpan(looks))
+		}
+	}
+	t := bisectScale(pfa, cfg.Pfa)
+	cfarScales.Store(key, t)
+	return t
+}
+
+// bisectScale finds the threshold where pfa, which falls with the
+// threshold, reaches want.
+func bisectScale(pfa func(float64) float64, want float64) float64 {
+	lo, hi := 0.0, 1.0
+	for pfa(hi) > want {
+		lo, hi = hi, hi*2
+	}
+	for hi-lo > 1e-6*hi {
+		mid := (lo + hi) / 2
+		if pfa(mid) > want {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+// cfarScaleExp is cfarScale for exponentially distributed noise power.
+func cfarScaleExp(cfg CFARConfig, n int) float64 {
+	if cfg.Method == CFARCellAverage {
+		// Pfa = (1+t/n)^-n over the mean of n cells.
+		return float64(n) * (math.Pow(cfg.Pfa, -1/float64(n)) - 1)
+	}
+	// Pfa = prod_{i<k} (n-i)/(n-i+t) for the k-th smallest of n cells.
+	k := cfarRank(cfg, n) + 1
+	return bisectScale(func(t float64) float64 {
+		p := 1.0
+		for i := 0; i < k; i++ {
+			p *= float64(n-i) / (float64(n-i) + t)
+		}
+		return p
+	}, cfg.Pfa)
+}
+
+func lgamma(x float64) float64 {
+	v, _ := math.Lgamma(x)
+	return v
+}
+
+// gammaPDF is the density of the unit scale gamma distribution.
+func gammaPDF(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return math.Exp((a-1)*math.Log(x) - x - lgamma(a))
+}
+
+// gammaQ is the regularized upper incomplete gamma function, the chance a
+// unit scale gamma variable exceeds x.
+func gammaQ(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	lnPre := a*math.Log(x) - x - lgamma(a)
+	if x < a+1 {
+		// Series for the lower function.
+		ap, del, sum := a, 1/a, 1/a
+		for i := 0; i < 1000 && math.Abs(del) > math.Abs(sum)*1e-15; i++ {
+			ap++
+			del *= x / ap
+			sum += del
+		}
+		return 1 - sum*math.Exp(lnPre)
+	}
+	// Continued fraction, by Lentz's method.
+	const tiny = 1e-300
+	b := x + 1 - a
+	c, d := 1/tiny, 1/b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		if d = an*d + b; math.Abs(d) < tiny {
+			d = tiny
+		}
+		if c = b + an/c; math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		if math.Abs(d*c-1) < 1e-15 {
+			break
+		}
+	}
+	return h * math.Exp(lnPre)
+}
+
+// gammaSpan covers nearly all of a gamma distribution's mass.
+func gammaSpan(a float64) [2]float64 {
+	sd := math.Sqrt(a)
+	return [2]float64{math.Max(0, a-12*sd), a + 12*sd + 12}
+}
+
+// integrate is Simpson's rule over the span.
+func integrate(f func(float64) float64, span [2]float64) float64 {
+	const n = 512
+	h := (span[1] - span[0]) / n
+	sum := f(span[0]) + f(span[1])
+	for i := 1; i < n; i++ {
+		w := 2.0
+		if i%2 == 1 {
+			w = 4
+		}
+		sum += w * f(span[0]+float64(i)*h)
+	}
+	return sum * h / 3
+}
+
+// Detect finds signals in the average spectrum, setting thresholds for
+// the number of FFTs averaged.
+func (sp *SpectralPower) Detect(cfg CFARConfig) (ret []Detection) {
+	for _, d := range cfar(sp.avg, cfg, sp.Looks()) {
+		ret = append(ret, Detection{FreqBand: sp.freq(d.binBand), SNR: d.snr, PeakDB: d.DB})
+	}
+	return ret
+}
@@ -0,0 +1,109 @@
+package radio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// cfarSpectrum is exponential noise at 0dB with signals added as
+// (center bin, width bins, dB) triples.
+func cfarSpectrum(rng *rand.Rand, bins int, sigs [][3]int) []float64 {
+	lin := make([]float64, bins)
+	for i := range lin {
+		lin[i] = rng.ExpFloat64()
+	}
+	for _, s := range sigs {
+		for i := s[0] - s[1]/2; i < s[0]+(s[1]+1)/2; i++ {
+			lin[i] += math.Pow(10, float64(s[2])/10)
+		}
+	}
+	db := make([]float64, bins)
+	for i, v := range lin {
+		db[i] = powerDB(v)
+	}
+	return db
+}
+
+func TestCFAR(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// A weak narrow signal next to a strong wide one.
+	sigs := [][3]int{{1000, 40, 50}, {1040, 4, 25}, {3000, 10, 30}}
+	pwr := cfarSpectrum(rng, 4096, sigs)
+
+	dets := cfar(pwr, CFARConfig{Method: CFAROrderStatistic}, 1)
+	if len(dets) != len(sigs) {
+		t.Fatalf("expected %d detections, got %+v", len(sigs), dets)
+	}
+	for i, d := range dets {
+		center, width := d.Begin+d.Bins/2, d.Bins
+		if abs := center - sigs[i][0]; abs < -2 || abs > 2 {
+			t.Errorf("detection %d at bin %d, expected %d", i, center, sigs[i][0])
+		}
+		if width < sigs[i][1]-2 || width > sigs[i][1]+2 {
+			t.Errorf("detection %d is %d bins wide, expected %d", i, width, sigs[i][1])
+		}
+		if math.Abs(d.snr-float64(sigs[i][2])) > 8 {
+			t.Errorf("detection %d has snr %.1f, expected ~%d", i, d.snr, sigs[i][2])
+		}
+	}
+
+	// Noise alone has no detections with either method.
+	for _, m := range []CFARMethod{CFARCellAverage, CFAROrderStatistic} {
+		falses := 0
+		for n := 0; n < 10; n++ {
+			falses += len(cfar(cfarSpectrum(rng, 4096, nil), CFARConfig{Method: m, Pfa: 1e-5}, 1))
+		}
+		if falses > 1 {
+			t.Errorf("%s: %d false alarms", m, falses)
+		}
+	}
+}
+
+func TestCFARGammaScale(t *testing.T) {
+	// The gamma thresholds approach the exponential ones at one look.
+	for _, m := range []CFARMethod{CFARCellAverage, CFAROrderStatistic} {
+		cfg := CFARConfig{Method: m}.withDefaults()
+		for _, n := range []int{16, 128} {
+			exp, gam := cfarScaleExp(cfg, n), cfarScale(cfg, n, 1+1e-9)
+			if math.Abs(gam/exp-1) > 0.01 {
+				t.Errorf("%s n=%d: gamma scale %.3f, exponential %.3f", m, n, gam, exp)
+			}
+		}
+	}
+}
+
+func TestDetectMeasured(t *testing.T) {
+	const bins, ffts = 1024, 64
+	rng := rand.New(rand.NewSource(1))
+	// Complex noise at 0dBFS with a tone 5dB over the noise in its bin,
+	// which is under the threshold for a single FFT.
+	ch := make(chan []complex64, ffts)
+	hann := 1.5
+	a := math.Sqrt(math.Pow(10, 0.5) * hann / bins)
+	const toneBin = 300
+	for n := 0; n < ffts; n++ {
+		samps := make([]complex64, bins)
+		for i := range samps {
+			ph := 2 * math.Pi * float64(toneBin-bins/2) * float64(n*bins+i) / bins
+			v := complex(rng.NormFloat64()/math.Sqrt2, rng.NormFloat64()/math.Sqrt2)
+			samps[i] = complex64(v + complex(a*math.Cos(ph), a*math.Sin(ph)))
+		}
+		ch <- samps
+	}
+	close(ch)
+	sp := NewSpectralPower(FreqBand{Center: 100, Width: 1.024}, bins, ffts)
+	if err := sp.Measure(ch); err != nil {
+		t.Fatal(err)
+	}
+	if l := sp.Looks(); l < ffts || l > 2*ffts {
+		t.Fatalf("expected %d to %d looks, got %.1f", ffts, 2*ffts, l)
+	}
+	dets := sp.Detect(CFARConfig{})
+	if len(dets) != 1 {
+		t.Fatalf("expected one detection, got %+v", dets)
+	}
+	if f := sp.freq(binBand{Begin: toneBin, Bins: 1}); math.Abs(dets[0].Center-f.Center) > 2*f.Width {
+		t.Fatalf("detected %+v, expected tone at %.4fMHz", dets[0], f.Center)
+	}
+}
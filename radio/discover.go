@@ -0,0 +1,313 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// USBDevice is a device's USB descriptor.
+type USBDevice struct {
+	// Path is the device's place on the bus, e.g. "1-1.2".
+	Path         string
+	Bus, Dev     int
+	Vendor       uint16
+	Product      uint16
+	Serial       string
+	Manufacturer string
+	ProductName  string
+}
+
+// DeviceSource enumerates USB devices.
+type DeviceSource interface {
+	Devices() ([]USBDevice, error)
+}
+
+// SysfsSource reads USB descriptors from sysfs on Linux.
+type SysfsSource struct {
+	// Root is the sysfs USB device directory; defaults to
+	// /sys/bus/usb/devices.
+	Root string
+}
+
+func (ss SysfsSource) Devices() (devs []USBDevice, err error) {
+	root := ss.Root
+	if root == "" {
+		root = "/sys/bus/usb/devices"
+	}
+	ents, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		// No sysfs; not Linux.
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, ent := range ents {
+		dir := filepath.Join(root, ent.Name())
+		attr := func(name string) string {
+			b, _ := os.ReadFile(filepath.Join(dir, name))
+			return strings.TrimSpace(string(b))
+		}
+		hexAttr := func(name string) uint16 {
+			v, _ := strconv.ParseUint(attr(name), 16, 16)
+			return uint16(v)
+		}
+		// Interfaces and hubs without descriptors have no vendor.
+		if attr("idVendor") == "" {
+			continue
+		}
+		bus, _ := strconv.Atoi(attr("busnum"))
+		dev, _ := strconv.Atoi(attr("devnum"))
+		devs = append(devs, USBDevice{
+			Path:         ent.Name(),
+			Bus:          bus,
+			Dev:          dev,
+			Vendor:       hexAttr("idVendor"),
+			Product:      hexAttr("idProduct"),
+			Serial:       attr("serial"),
+			Manufacturer: attr("manufacturer"),
+			ProductName:  attr("product"),
+		})
+	}
+	return devs, nil
+}
+
+// rtlUSBIDs are the vendor and product ids librtlsdr knows.
+var rtlUSBIDs = map[[2]uint16]struct{}{
+	{0x0bda, 0x2832}: {}, {0x0bda, 0x2838}: {},
+	{0x0413, 0x6680}: {}, {0x0413, 0x6f0f}: {},
+	{0x0458, 0x707f}: {}, {0x0ccd, 0x00a9}: {},
+	{0x0ccd, 0x00b3}: {}, {0x0ccd, 0x00b4}: {},
+	{0x0ccd, 0x00b5}: {}, {0x0ccd, 0x00b7}: {},
+	{0x0ccd, 0x00b8}: {}, {0x0ccd, 0x00b9}: {},
+	{0x0ccd, 0x00c0}: {}, {0x0ccd, 0x00c6}: {},
+	{0x0ccd, 0x00d3}: {}, {0x0ccd, 0x00d7}: {},
+	{0x0ccd, 0x00e0}: {}, {0x1554, 0x5020}: {},
+	{0x15f4, 0x0131}: {}, {0x15f4, 0x0133}: {},
+	{0x185b, 0x0620}: {}, {0x185b, 0x0650}: {},
+	{0x185b, 0x0680}: {}, {0x1b80, 0xd393}: {},
+	{0x1b80, 0xd394}: {}, {0x1b80, 0xd395}: {},
+	{0x1b80, 0xd397}: {}, {0x1b80, 0xd398}: {},
+	{0x1b80, 0xd39d}: {}, {0x1b80, 0xd3a4}: {},
+	{0x1b80, 0xd3a8}: {}, {0x1b80, 0xd3af}: {},
+	{0x1b80, 0xd3b0}: {}, {0x1d19, 0x1101}: {},
+	{0x1d19, 0x1102}: {}, {0x1d19, 0x1103}: {},
+	{0x1d19, 0x1104}: {}, {0x1f4d, 0xa803}: {},
+	{0x1f4d, 0xb803}: {}, {0x1f4d, 0xc803}: {},
+	{0x1f4d, 0xd286}: {}, {0x1f4d, 0xd803}: {},
+}
+
+// rtlSDRInfos picks out the RTL-SDR dongles. Ids are serials unless
+// dongles share a serial, in which case they get their librtlsdr index.
+func rtlSDRInfos(devs []USBDevice) (ret []SDRHWInfo) {
+	var rtls []USBDevice
+	for _, d := range devs {
+		if _, ok := rtlUSBIDs[[2]uint16{d.Vendor, d.Product}]; ok {
+			rtls = append(rtls, d)
+		}
+	}
+	// librtlsdr numbers devices in bus order.
+	sort.Slice(rtls, func(i, j int) bool {
+		if rtls[i].Bus != rtls[j].Bus {
+			return rtls[i].Bus < rtls[j].Bus
+		}
+		return rtls[i].Dev < rtls[j].Dev
+	})
+	serials := make(map[string]int)
+	for _, d := range rtls {
+		serials[d.Serial]++
+	}
+	for i, d := range rtls {
+		id := d.Serial
+		if id == "" || serials[id] > 1 {
+			id = strconv.Itoa(i)
+		}
+		ret = append(ret, SDRHWInfo{
+			Id:            id,
+			MinHz:         uint64(minFreqHz),
+			MaxHz:         uint64(maxFreqHz),
+			MinSampleRate: minRate,
+			MaxSampleRate: maxRate,
+			Gain:          DefaultGain,
+			SDRFormat:     SDRFormat{BitDepth: 8, Format: FormatCU8},
+		})
+	}
+	return ret
+}
+
+type DeviceEventType string
+
+const (
+	DeviceAdded   DeviceEventType = "add"
+	DeviceRemoved DeviceEventType = "remove"
+)
+
+// DeviceEvent is a radio being plugged in or unplugged.
+type DeviceEvent struct {
+	Type   DeviceEventType `json:"type"`
+	Device SDRHWInfo       `json:"device"`
+	Time   time.Time       `json:"time"`
+}
+
+// How long a scan is reused before the bus is read again.
+const discoveryTTL = 2 * time.Second
+
+// How often the bus is scanned while there are event subscribers.
+const discoveryPoll = time.Second
+
+// Discovery caches the radios found on a device source and reports radios
+// coming and going.
+type Discovery struct {
+	src DeviceSource
+
+	devs    []SDRHWInfo
+	scanned time.Time
+	inUse   map[string]bool
+
+	subs  map[chan DeviceEvent]struct{}
+	stopc chan struct{}
+
+	mu sync.Mutex
+}
+
+// DefaultDiscovery finds radios through sysfs.
+var DefaultDiscovery = NewDiscovery(SysfsSource{})
+
+func NewDiscovery(src DeviceSource) *Discovery {
+	return &Discovery{
+		src:   src,
+		inUse: make(map[string]bool),
+		subs:  make(map[chan DeviceEvent]struct{}),
+	}
+}
+
+// Refresh rescans the source, notifying subscribers of any changes.
+func (d *Discovery) Refresh() error {
+	usbs, err := d.src.Devices()
+	if err != nil {
+		return err
+	}
+	devs := rtlSDRInfos(usbs)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	var evs []DeviceEvent
+	if !d.scanned.IsZero() {
+		evs = append(evs, diffDevices(DeviceAdded, devs, d.devs, now)...)
+		evs = append(evs, diffDevices(DeviceRemoved, d.devs, devs, now)...)
+	}
+	d.devs, d.scanned = devs, now
+	for _, ev := range evs {
+		log.Printf("sdr %s: %s", ev.Type, ev.Device.Id)
+		for c := range d.subs {
+			select {
+			case c <- ev:
+			default:
+				log.Printf("dropped sdr event for slow subscriber")
+			}
+		}
+	}
+	return nil
+}
+
+// diffDevices is the devices in a but not b.
+func diffDevices(t DeviceEventType, a, b []SDRHWInfo, now time.Time) (evs []DeviceEvent) {
+	ids := make(map[string]struct{}, len(b))
+	for _, dev := range b {
+		ids[dev.Id] = struct{}{}
+	}
+	for _, dev := range a {
+		if _, ok := ids[dev.Id]; !ok {
+			evs = append(evs, DeviceEvent{Type: t, Device: dev, Time: now})
+		}
+	}
+	return evs
+}
+
+// List is the radios found on the source, rescanning if the cached scan is
+// stale.
+func (d *Discovery) List() ([]SDRHWInfo, error) {
+	d.mu.Lock()
+	stale := time.Since(d.scanned) > discoveryTTL
+	d.mu.Unlock()
+	if stale {
+		if err := d.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ret := make([]SDRHWInfo, len(d.devs))
+	for i, dev := range d.devs {
+		dev.InUse = d.inUse[dev.Id]
+		ret[i] = dev
+	}
+	return ret, nil
+}
+
+// SetInUse marks whether a radio is held open.
+func (d *Discovery) SetInUse(id string, inUse bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if inUse {
+		d.inUse[id] = true
+	} else {
+		delete(d.inUse, id)
+	}
+}
+
+// Subscribe streams radios being added and removed until ctx is done. The
+// source is polled while anything is subscribed.
+func (d *Discovery) Subscribe(ctx context.Context) <-chan DeviceEvent {
+	c := make(chan DeviceEvent, 16)
+	d.mu.Lock()
+	d.subs[c] = struct{}{}
+	if len(d.subs) == 1 {
+		d.stopc = make(chan struct{})
+		go d.poll(d.stopc)
+	}
+	d.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.subs, c)
+		close(c)
+		if len(d.subs) == 0 {
+			close(d.stopc)
+		}
+	}()
+	return c
+}
+
+func (d *Discovery) poll(stopc <-chan struct{}) {
+	t := time.NewTicker(discoveryPoll)
+	defer t.Stop()
+	for {
+		if err := d.Refresh(); err != nil {
+			log.Printf("discovering sdrs: %v", err)
+		}
+		select {
+		case <-stopc:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// SDRList is the discovered radios followed by the simulated ones.
+func (d *Discovery) SDRList() ([]SDRHWInfo, error) {
+	sdrs, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	return append(sdrs, simSDRList()...), nil
+}
@@ -0,0 +1,126 @@
+package radio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	devs []USBDevice
+	mu   sync.Mutex
+}
+
+func (fs *fakeSource) Devices() ([]USBDevice, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]USBDevice(nil), fs.devs...), nil
+}
+
+func (fs *fakeSource) set(devs ...USBDevice) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.devs = devs
+}
+
+func TestDiscoverEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	keyboard := USBDevice{Bus: 1, Dev: 2, Vendor: 0x046d, Product: 0xc31c}
+	a := USBDevice{Bus: 1, Dev: 3, Vendor: 0x0bda, Product: 0x2838, Serial: "A"}
+	b := USBDevice{Bus: 2, Dev: 1, Vendor: 0x0bda, Product: 0x2832, Serial: "B"}
+	src := &fakeSource{}
+	src.set(keyboard, a)
+	d := NewDiscovery(src)
+
+	sdrs, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sdrs) != 1 || sdrs[0].Id != "A" || sdrs[0].InUse {
+		t.Fatalf("expected idle sdr A, got %+v", sdrs)
+	}
+	d.SetInUse("A", true)
+	if sdrs, _ = d.List(); !sdrs[0].InUse {
+		t.Fatal("expected sdr A in use")
+	}
+
+	evc := d.Subscribe(ctx)
+	expect := func(typ DeviceEventType, id string) {
+		select {
+		case ev := <-evc:
+			if ev.Type != typ || ev.Device.Id != id {
+				t.Fatalf("expected %s %s, got %s %s", typ, id, ev.Type, ev.Device.Id)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s %s", typ, id)
+		}
+	}
+	src.set(keyboard, a, b)
+	expect(DeviceAdded, "B")
+	src.set(b)
+	expect(DeviceRemoved, "A")
+
+	cancel()
+	for range evc {
+	}
+}
+
+func TestDiscoverDuplicateSerials(t *testing.T) {
+	// Dongles commonly ship with the same serial; fall back to indexes.
+	src := &fakeSource{}
+	src.set(
+		USBDevice{Bus: 1, Dev: 5, Vendor: 0x0bda, Product: 0x2838, Serial: "00000001"},
+		USBDevice{Bus: 1, Dev: 4, Vendor: 0x0bda, Product: 0x2838, Serial: "00000001"},
+		USBDevice{Bus: 1, Dev: 6, Vendor: 0x0bda, Product: 0x2838, Serial: "C"},
+	)
+	sdrs, err := NewDiscovery(src).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range sdrs {
+		ids = append(ids, s.Id)
+	}
+	if len(ids) != 3 || ids[0] != "0" || ids[1] != "1" || ids[2] != "C" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+}
+
+func TestDiscoverSysfs(t *testing.T) {
+	root := t.TempDir()
+	write := func(dev, name, val string) {
+		dir := filepath.Join(root, dev)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(val+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1-1", "idVendor", "0bda")
+	write("1-1", "idProduct", "2838")
+	write("1-1", "serial", "00000042")
+	write("1-1", "busnum", "1")
+	write("1-1", "devnum", "7")
+	write("1-1:1.0", "bInterfaceClass", "ff")
+
+	devs, err := SysfsSource{Root: root}.Devices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 {
+		t.Fatalf("expected 1 device, got %+v", devs)
+	}
+	if d := devs[0]; d.Vendor != 0x0bda || d.Product != 0x2838 || d.Serial != "00000042" || d.Dev != 7 {
+		t.Fatalf("bad descriptor %+v", d)
+	}
+
+	devs, err = SysfsSource{Root: filepath.Join(root, "missing")}.Devices()
+	if err != nil || len(devs) != 0 {
+		t.Fatalf("expected no devices without sysfs, got %v %v", devs, err)
+	}
+}
@@ -0,0 +1,107 @@
+package radio
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// DriftSample is one measurement of a radio's frequency error.
+type DriftSample struct {
+	Time time.Time `json:"time"`
+	// PPM is the total crystal error, including the applied correction.
+	PPM float64 `json:"ppm"`
+	// AppliedPPM is the correction in effect after the measurement.
+	AppliedPPM int32 `json:"applied_ppm"`
+}
+
+type DriftConfig struct {
+	Reference CalReference `json:"reference"`
+	// Interval is the time between measurements; defaults to 10 minutes.
+	Interval time.Duration `json:"interval"`
+	// ThresholdPPM is how far the error may drift from the applied
+	// correction before it is corrected; defaults to 1.
+	ThresholdPPM float64 `json:"threshold_ppm"`
+	// History is the number of samples kept; defaults to 144.
+	History int `json:"history"`
+}
+
+// DriftMonitor periodically re-measures a radio's frequency error and
+// corrects it as the crystal warms up.
+type DriftMonitor struct {
+	cfg DriftConfig
+	cal *CalibrationTable
+
+	last time.Time
+	hist []DriftSample
+	mu   sync.RWMutex
+}
+
+// NewDriftMonitor tracks drift against the configured reference. Corrections
+// are recorded in cal if it is not nil.
+func NewDriftMonitor(cfg DriftConfig, cal *CalibrationTable) *DriftMonitor {
+	if cfg.Reference.Type == "" {
+		cfg.Reference = DefaultCalReference
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	if cfg.ThresholdPPM <= 0 {
+		cfg.ThresholdPPM = 1
+	}
+	if cfg.History <= 0 {
+		cfg.History = 144
+	}
+	return &DriftMonitor{cfg: cfg, cal: cal}
+}
+
+// Due is true once the interval has passed since the last measurement.
+func (dm *DriftMonitor) Due() bool {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return time.Since(dm.last) >= dm.cfg.Interval
+}
+
+// Measure takes a drift sample, correcting the SDR if the error passed the
+// threshold. The SDR's tuning is restored afterwards.
+func (dm *DriftMonitor) Measure(sdr SDR) (DriftSample, error) {
+	dm.mu.Lock()
+	dm.last = time.Now()
+	dm.mu.Unlock()
+
+	info := sdr.Info()
+	residual, err := MeasurePPM(sdr, dm.cfg.Reference)
+	if err == nil && math.Abs(residual) > dm.cfg.ThresholdPPM {
+		ppm := float64(info.PPM) + residual
+		log.Printf("%s drifted %.2fppm to %.2fppm; correcting", info.Id, residual, ppm)
+		if err = sdr.SetFreqCorrection(uint32(int32(math.Round(ppm)))); err == nil && dm.cal != nil {
+			err = dm.cal.Set(info.Id, Calibration{PPM: ppm, Reference: dm.cfg.Reference, Time: dm.last})
+		}
+	}
+	if info.SampleRate != 0 {
+		if serr := sdr.SetBand(info.HzBand()); err == nil {
+			err = serr
+		}
+	}
+	if err != nil {
+		return DriftSample{}, err
+	}
+
+	ds := DriftSample{Time: dm.last, PPM: float64(info.PPM) + residual, AppliedPPM: sdr.Info().PPM}
+	log.Printf("%s error %.2fppm, applied %dppm", info.Id, ds.PPM, ds.AppliedPPM)
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.hist = append(dm.hist, ds)
+	if len(dm.hist) > dm.cfg.History {
+		dm.hist = dm.hist[len(dm.hist)-dm.cfg.History:]
+	}
+	return ds, nil
+}
+
+// History is the drift samples, oldest first.
+func (dm *DriftMonitor) History() []DriftSample {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	return append([]DriftSample(nil), dm.hist...)
+}
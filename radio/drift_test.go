@@ -0,0 +1,50 @@
+package radio
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDriftMonitor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	scene := SimScene{
+		NoiseDB: -50,
+		PPM:     10,
+		Signals: []SimSignal{{Type: SimCarrier, Hz: 144390000, DB: -20}},
+	}
+	sdr := NewSimSDR(ctx, "sim:drift", scene)
+	defer sdr.Close()
+	band := HzBand{Center: 100000000, Width: 240000}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+
+	dm := NewDriftMonitor(DriftConfig{
+		Reference: CalReference{Type: CalBeacon, Hz: 144390000},
+		Interval:  time.Hour,
+	}, nil)
+	if !dm.Due() {
+		t.Fatal("expected first measurement to be due")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := dm.Measure(sdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dm.Due() {
+		t.Fatal("expected no measurement due")
+	}
+	hist := dm.History()
+	if len(hist) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(hist))
+	}
+	// The first sample corrects the error; the second sees it fixed.
+	if hist[0].AppliedPPM != 10 || hist[1].AppliedPPM != 10 {
+		t.Fatalf("expected 10ppm applied, got %+v", hist)
+	}
+	if info := sdr.Info(); info.HzBand() != band {
+		t.Fatalf("expected tuning restored to %+v, got %+v", band, info.HzBand())
+	}
+}
@@ -0,0 +1,262 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const execPrefix = "exec:"
+
+// ExecSDRConfig describes a program that streams samples to stdout, such
+// as hackrf_transfer, airspy_rx, or rx_sdr.
+type ExecSDRConfig struct {
+	// Command is run by sh with {freq} and {rate} replaced by the tuned
+	// band in Hz, {ppm} by the frequency correction, and {gain} by the
+	// tuner gain in dB.
+	Command string
+	// Format is the sample encoding written by the program.
+	Format SampleFormat
+
+	// Tuning limits; default to 0-6GHz and 1kHz-20MHz.
+	MinHz, MaxHz                 uint64
+	MinSampleRate, MaxSampleRate uint32
+}
+
+type execSDR struct {
+	ser string
+	cfg ExecSDRConfig
+
+	band HzBand
+	ppm  int32
+	gain GainConfig
+
+	proc *execProc
+	iqr  *MixerIQReader
+
+	ctx context.Context
+	mu  sync.Mutex
+}
+
+// NewExecSDR runs the configured command on every retune and reads its
+// samples from stdout.
+func NewExecSDR(ctx context.Context, ser string, cfg ExecSDRConfig) (SDR, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("no command for %q", ser)
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatCU8
+	}
+	if cfg.MaxHz == 0 {
+		cfg.MaxHz = 6000000000
+	}
+	if cfg.MinSampleRate == 0 {
+		cfg.MinSampleRate = 1000
+	}
+	if cfg.MaxSampleRate == 0 {
+		cfg.MaxSampleRate = 20000000
+	}
+	return &execSDR{ser: ser, cfg: cfg, gain: DefaultGain, ctx: ctx}, nil
+}
+
+// newExecSDRWithSerial parses serials of the form "exec:format:command",
+// e.g. "exec:cs8:hackrf_transfer -r - -f {freq} -s {rate}".
+func newExecSDRWithSerial(ctx context.Context, ser string) (SDR, error) {
+	f, cmd, ok := strings.Cut(strings.TrimPrefix(ser, execPrefix), ":")
+	if !ok {
+		return nil, fmt.Errorf("expected exec:format:command, got %q", ser)
+	}
+	format, err := ParseSampleFormat(f)
+	if err != nil {
+		return nil, err
+	}
+	return NewExecSDR(ctx, ser, ExecSDRConfig{Command: cmd, Format: format})
+}
+
+func (s *execSDR) command() string {
+	return strings.NewReplacer(
+		"{freq}", strconv.FormatUint(s.band.Center, 10),
+		"{rate}", strconv.FormatUint(s.band.Width, 10),
+		"{ppm}", strconv.Itoa(int(s.ppm)),
+		"{gain}", strconv.FormatFloat(s.gain.DB(), 'f', -1, 64),
+	).Replace(s.cfg.Command)
+}
+
+func (s *execSDR) SetBand(b HzBand) error {
+	if b.Center < s.cfg.MinHz || b.Center > s.cfg.MaxHz {
+		return ErrFrequencyOutOfRange
+	}
+	if uint32(b.Width) < s.cfg.MinSampleRate || uint32(b.Width) > s.cfg.MaxSampleRate {
+		return ErrRateOutOfRange
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.band = b
+	return s.restart()
+}
+
+// SetFreqCorrection restarts the program if its command takes {ppm}.
+func (s *execSDR) SetFreqCorrection(ppm uint32) error {
+	return s.setParam("{ppm}", int32(ppm) == 0, func() { s.ppm = int32(ppm) })
+}
+
+// SetGain restarts the program if its command takes {gain}.
+func (s *execSDR) SetGain(g GainConfig) error {
+	return s.setParam("{gain}", g.TenthDB == DefaultGain.TenthDB, func() { s.gain = g })
+}
+
+func (s *execSDR) setParam(param string, isDefault bool, set func()) error {
+	if !strings.Contains(s.cfg.Command, param) {
+		if isDefault {
+			return nil
+		}
+		return ErrUnsupported
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set()
+	if s.proc == nil {
+		return nil
+	}
+	return s.restart()
+}
+
+func (s *execSDR) restart() (err error) {
+	s.stop()
+	s.proc, err = startExecProc(s.ctx, s.command())
+	return err
+}
+
+func (s *execSDR) stop() {
+	if s.proc != nil {
+		s.proc.close()
+		s.proc, s.iqr = nil, nil
+	}
+}
+
+func (s *execSDR) Info() SDRHWInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SDRHWInfo{
+		Id: s.ser,
+		SDRFormat: SDRFormat{
+			BitDepth:   s.cfg.Format.BitDepth(),
+			Format:     s.cfg.Format,
+			CenterHz:   s.band.Center,
+			SampleRate: uint32(s.band.Width),
+		},
+		MinHz:         s.cfg.MinHz,
+		MaxHz:         s.cfg.MaxHz,
+		MinSampleRate: s.cfg.MinSampleRate,
+		MaxSampleRate: s.cfg.MaxSampleRate,
+		Gain:          s.gain,
+		PPM:           s.ppm,
+	}
+}
+
+func (s *execSDR) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	return nil
+}
+
+// Reader reads the running program's samples; it ends when the SDR is
+// retuned.
+func (s *execSDR) Reader() *MixerIQReader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proc == nil {
+		return NewMixerIQReader(&eofReader{}, s.band)
+	} else if s.iqr == nil {
+		s.iqr = NewIQReaderFormat(s.proc, s.cfg.Format).ToMixer(s.band)
+	}
+	return s.iqr
+}
+
+// execProc is a running sample program.
+type execProc struct {
+	cmd    *exec.Cmd
+	stdout *os.File
+	stderr *tailWriter
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+func startExecProc(ctx context.Context, command string) (*execProc, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	// Kill the whole group so the shell doesn't leave the program running.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	p := &execProc{cmd: cmd, stdout: pr, stderr: &tailWriter{max: 512}}
+	cmd.Stdout, cmd.Stderr = pw, p.stderr
+	log.Printf("starting %q", command)
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Read returns the program's exit error once its output ends.
+func (p *execProc) Read(b []byte) (int, error) {
+	n, err := p.stdout.Read(b)
+	if err == io.EOF {
+		if werr := p.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (p *execProc) wait() error {
+	p.waitOnce.Do(func() {
+		if err := p.cmd.Wait(); err != nil {
+			p.waitErr = fmt.Errorf("%s: %w: %s", p.cmd.Args[2], err, p.stderr)
+		}
+	})
+	return p.waitErr
+}
+
+func (p *execProc) close() {
+	syscall.Kill(-p.cmd.Process.Pid, syscall.SIGKILL)
+	p.wait()
+	p.stdout.Close()
+}
+
+// tailWriter keeps the last max bytes written.
+type tailWriter struct {
+	max int
+	buf []byte
+	mu  sync.Mutex
+}
+
+func (w *tailWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, b...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(b), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.TrimSpace(string(w.buf))
+}
@@ -0,0 +1,61 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecSDR(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	tuning := filepath.Join(t.TempDir(), "tuning")
+	sdr, err := NewSDRWithSerial(ctx, "exec:cu8:sh testdata/exec_sdr.sh {freq} {rate} "+tuning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+
+	for _, b := range []HzBand{{Center: 100000000, Width: 240000}, {Center: 433920000, Width: 1024000}} {
+		if err := sdr.SetBand(b); err != nil {
+			t.Fatal(err)
+		}
+		samps, ok := <-sdr.Reader().BatchStream64(ctx, 1024, 1)
+		if !ok {
+			t.Fatalf("no samples: %v", sdr.Reader().Err())
+		}
+		// The stream alternates 1, j; find the phase.
+		off := 0
+		if real(samps[0]) < 0.5 {
+			off = 1
+		}
+		for i, s := range samps {
+			want := complex64(1)
+			if (i+off)%2 == 1 {
+				want = 1i
+			}
+			if d := s - want; math.Hypot(float64(real(d)), float64(imag(d))) > 0.01 {
+				t.Fatalf("sample %d: got %v, expected %v", i, s, want)
+			}
+		}
+		got, err := os.ReadFile(tuning)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("%d %d", b.Center, b.Width); strings.TrimSpace(string(got)) != want {
+			t.Fatalf("program tuned to %q, expected %q", got, want)
+		}
+		if info := sdr.Info(); info.HzBand() != b {
+			t.Fatalf("info has band %+v, expected %+v", info.HzBand(), b)
+		}
+	}
+
+	if err := sdr.SetGain(GainConfig{TenthDB: 100}); err != ErrUnsupported {
+		t.Fatalf("expected unsupported gain, got %v", err)
+	}
+}
@@ -2,26 +2,269 @@ package radio
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
 	"io"
 	"log"
+	"math"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// SampleFormat is the encoding of interleaved I/Q samples.
+type SampleFormat string
+
+const (
+	// FormatCU8 is unsigned 8-bit I/Q, as sent by rtl_tcp.
+	FormatCU8 SampleFormat = "cu8"
+	// FormatCS8 is signed 8-bit I/Q.
+	FormatCS8 SampleFormat = "cs8"
+	// FormatCS16LE is signed 16-bit little-endian I/Q.
+	FormatCS16LE SampleFormat = "cs16le"
+	// FormatCF32LE is 32-bit little-endian float I/Q.
+	FormatCF32LE SampleFormat = "cf32le"
+)
+
+// ParseSampleFormat accepts a format name or file extension; empty is cu8.
+func ParseSampleFormat(s string) (SampleFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "", "cu8", "iq8", "u8":
+		return FormatCU8, nil
+	case "cs8", "s8", "ci8":
+		return FormatCS8, nil
+	case "cs16le", "cs16", "s16", "ci16_le":
+		return FormatCS16LE, nil
+	case "cf32le", "cf32", "cfile", "fc32", "cf32_le":
+		return FormatCF32LE, nil
+	}
+	return "", fmt.Errorf("unknown sample format %q", s)
+}
+
+// PathSampleFormat guesses the sample format from a file extension,
+// defaulting to cu8.
+func PathSampleFormat(path string) SampleFormat {
+	f, err := ParseSampleFormat(filepath.Ext(path))
+	if err != nil {
+		return FormatCU8
+	}
+	return f
+}
+
+// BitDepth is the number of bits in each I or Q component.
+func (f SampleFormat) BitDepth() uint { return uint(f.SampleBytes() * 4) }
+
+// SampleBytes is the number of bytes in one I/Q pair.
+func (f SampleFormat) SampleBytes() int {
+	switch f {
+	case FormatCS16LE:
+		return 4
+	case FormatCF32LE:
+		return 8
+	}
+	return 2
+}
+
+// Ext is the file extension for the format.
+func (f SampleFormat) Ext() string {
+	switch f {
+	case FormatCS8:
+		return ".cs8"
+	case FormatCS16LE:
+		return ".cs16"
+	case FormatCF32LE:
+		return ".cf32"
+	}
+	return ".iq8"
+}
+
+// SigMFDatatype is the SigMF core:datatype for the format.
+func (f SampleFormat) SigMFDatatype() string {
+	switch f {
+	case FormatCS8:
+		return "ci8"
+	case FormatCS16LE:
+		return "ci16_le"
+	case FormatCF32LE:
+		return "cf32_le"
+	}
+	return "cu8"
+}
+
+func (f SampleFormat) decode(out []complex64, buf []byte) {
+	switch f {
+	case FormatCS8:
+		for i := range out {
+			out[i] = complex(
+				float32(int8(buf[2*i]))/128.0,
+				float32(int8(buf[2*i+1]))/128.0)
+		}
+	case FormatCS16LE:
+		for i := range out {
+			out[i] = complex(
+				float32(int16(binary.LittleEndian.Uint16(buf[4*i:])))/32768.0,
+				float32(int16(binary.LittleEndian.Uint16(buf[4*i+2:])))/32768.0)
+		}
+	case FormatCF32LE:
+		for i := range out {
+			out[i] = complex(
+				math.Float32frombits(binary.LittleEndian.Uint32(buf[8*i:])),
+				math.Float32frombits(binary.LittleEndian.Uint32(buf[8*i+4:])))
+		}
+	default:
+		for i := range out {
+			out[i] = complex(
+				(float32(buf[2*i])-127)/128.0,
+				(float32(buf[2*i+1])-127)/128.0)
+		}
+	}
+}
+
+func (f SampleFormat) encode(buf []byte, in []complex64) {
+	switch f {
+	case FormatCS8:
+		for i, v := range in {
+			buf[2*i] = byte(int8(clampRound(real(v)*128.0, -128, 127)))
+			buf[2*i+1] = byte(int8(clampRound(imag(v)*128.0, -128, 127)))
+		}
+	case FormatCS16LE:
+		for i, v := range in {
+			binary.LittleEndian.PutUint16(buf[4*i:], uint16(int16(clampRound(real(v)*32768.0, -32768, 32767))))
+			binary.LittleEndian.PutUint16(buf[4*i+2:], uint16(int16(clampRound(imag(v)*32768.0, -32768, 32767))))
+		}
+	case FormatCF32LE:
+		for i, v := range in {
+			binary.LittleEndian.PutUint32(buf[8*i:], math.Float32bits(real(v)))
+			binary.LittleEndian.PutUint32(buf[8*i+4:], math.Float32bits(imag(v)))
+		}
+	default:
+		for i, v := range in {
+			buf[2*i] = byte(clamp(real(v)*128.0+127.0, 0, 255))
+			buf[2*i+1] = byte(clamp(imag(v)*128.0+127.0, 0, 255))
+		}
+	}
+}
+
+func clamp(v, lo, hi float32) float32 {
+	if v < lo {
+		return lo
+	} else if v > hi {
+		return hi
+	}
+	return v
+}
+
+func clampRound(v, lo, hi float32) float32 {
+	return clamp(float32(math.Round(float64(v))), lo, hi)
+}
+
 type IQReader struct {
-	r     io.Reader
-	err   error
-	mu    sync.Mutex
-	batch int
-	chans map[*iqChannel]struct{}
+	r      io.Reader
+	format SampleFormat
+	err    error
+	mu     sync.Mutex
+	chans  map[*iqChannel]struct{}
+	// index is the number of samples read so far.
+	index uint64
+	// rate is the sample rate, if known, for timestamping batches.
+	rate atomic.Uint64
+}
+
+// IQBatch is a batch of samples with its position in the stream.
+type IQBatch struct {
+	Samples []complex64
+	// Index is the absolute index of the first sample in the stream.
+	Index uint64
+	// Time is the estimated wall-clock time of the first sample.
+	Time time.Time
+	// Dropped is the number of samples lost right before this batch.
+	Dropped uint64
+}
+
+// IQStats counts what happened to a subscriber's samples.
+type IQStats struct {
+	// Samples is the number of samples delivered.
+	Samples uint64 `json:"samples"`
+	// Dropped is the number of samples lost to overruns.
+	Dropped uint64 `json:"dropped"`
+	// Overruns is the number of times the subscriber fell behind.
+	Overruns uint64 `json:"overruns"`
+}
+
+// Backpressure is what a subscriber's stream does when it falls behind.
+type Backpressure string
+
+const (
+	// BackpressureDisconnect drops samples for a stalled subscriber and
+	// closes its stream if it stays behind past the timeout.
+	BackpressureDisconnect Backpressure = "disconnect"
+	// BackpressureBlock never drops samples; a stalled subscriber holds
+	// up every other subscriber on the reader.
+	BackpressureBlock Backpressure = "block"
+	// BackpressureDropOldest never waits; a stalled subscriber loses its
+	// oldest buffered batches to make room for new ones.
+	BackpressureDropOldest Backpressure = "drop_oldest"
+)
+
+// ParseBackpressure accepts a policy name; empty is disconnect.
+func ParseBackpressure(s string) (Backpressure, error) {
+	switch Backpressure(strings.ToLower(s)) {
+	case "", BackpressureDisconnect:
+		return BackpressureDisconnect, nil
+	case BackpressureBlock:
+		return BackpressureBlock, nil
+	case BackpressureDropOldest, "drop-oldest":
+		return BackpressureDropOldest, nil
+	}
+	return "", fmt.Errorf("unknown backpressure policy %q", s)
+}
+
+// StreamConfig configures a subscription to an IQReader.
+type StreamConfig struct {
+	// Batch is the number of samples in each batch.
+	Batch int
+	// Limit closes the stream after this many batches if nonzero.
+	Limit int
+	// Backpressure defaults to BackpressureDisconnect.
+	Backpressure Backpressure
+	// Buffer is the number of batches queued for the subscriber; defaults
+	// to 4.
+	Buffer int
+	// Timeout is how long a disconnect subscriber may drop samples before
+	// it is closed; defaults to one second.
+	Timeout time.Duration
+}
+
+// IQStream is a subscription to an IQReader.
+type IQStream struct {
+	C   <-chan IQBatch
+	iqc *iqChannel
 }
 
 type iqChannel struct {
-	batch int
-	limit int
-	sent  int
-	c     chan []complex64
-	ctx   context.Context
+	batch   int
+	limit   int
+	sent    int
+	policy  Backpressure
+	timeout time.Duration
+	c       chan IQBatch
+	ctx     context.Context
+
+	// pending holds samples short of a full batch, starting at
+	// pendingIndex read at pendingTime.
+	pending      []complex64
+	pendingIndex uint64
+	pendingTime  time.Time
+
+	// stalled is when sends started failing, if they are failing.
+	stalled time.Time
+	dropped uint64
+
+	samples  atomic.Uint64
+	drops    atomic.Uint64
+	overruns atomic.Uint64
 }
 
 type MixerIQReader struct {
@@ -31,97 +274,187 @@ type MixerIQReader struct {
 
 // NewIQReader takes a reader that uses u8 I/Q samples.
 func NewIQReader(r io.Reader) *IQReader {
+	return NewIQReaderFormat(r, FormatCU8)
+}
+
+// NewIQReaderFormat takes a reader that uses I/Q samples encoded by f.
+func NewIQReaderFormat(r io.Reader, f SampleFormat) *IQReader {
 	if r == nil {
 		panic("nil reader")
 	}
-	return &IQReader{r: r, chans: make(map[*iqChannel]struct{})}
+	return &IQReader{r: r, format: f, chans: make(map[*iqChannel]struct{})}
+}
+
+func (iq *IQReader) Format() SampleFormat { return iq.format }
+
+// Err is why the reader stopped, or nil if it reached the end of its
+// input. It is only valid once the reader's streams have closed.
+func (iq *IQReader) Err() error {
+	if iq.err == io.EOF {
+		return nil
+	}
+	return iq.err
 }
 
 func (iqr *IQReader) ToMixer(hzb HzBand) *MixerIQReader {
+	iqr.rate.Store(hzb.Width)
 	return &MixerIQReader{HzBand: hzb, IQReader: iqr}
 }
 
 func NewMixerIQReader(r io.Reader, hzb HzBand) *MixerIQReader {
-	return &MixerIQReader{
-		HzBand:   hzb,
-		IQReader: NewIQReader(r),
-	}
+	return NewIQReader(r).ToMixer(hzb)
 }
 
 func (iq *IQReader) Batch64(batch, limit int) <-chan []complex64 {
 	return iq.BatchStream64(context.Background(), batch, limit)
 }
 
+// BatchStream64 streams samples in batches of the given size. Subscribers
+// may use different batch sizes on the same reader.
 func (iq *IQReader) BatchStream64(ctx context.Context, batch, limit int) <-chan []complex64 {
-	iqc := &iqChannel{limit: limit, c: make(chan []complex64, 4), ctx: ctx}
+	return iq.Subscribe(ctx, batch, limit).Samples(ctx)
+}
+
+// BatchStreamConfig64 streams samples as configured by cfg.
+func (iq *IQReader) BatchStreamConfig64(ctx context.Context, cfg StreamConfig) <-chan []complex64 {
+	return iq.SubscribeConfig(ctx, cfg).Samples(ctx)
+}
+
+// Subscribe streams timestamped batches of the given size, closing the
+// stream after limit batches if limit is nonzero.
+func (iq *IQReader) Subscribe(ctx context.Context, batch, limit int) *IQStream {
+	return iq.SubscribeConfig(ctx, StreamConfig{Batch: batch, Limit: limit})
+}
+
+// SubscribeConfig streams timestamped batches as configured by cfg.
+func (iq *IQReader) SubscribeConfig(ctx context.Context, cfg StreamConfig) *IQStream {
+	if cfg.Batch <= 0 {
+		panic("bad batch")
+	}
+	if cfg.Backpressure == "" {
+		cfg.Backpressure = BackpressureDisconnect
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 4
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = stallTimeout
+	}
+	iqc := &iqChannel{
+		batch:   cfg.Batch,
+		limit:   cfg.Limit,
+		policy:  cfg.Backpressure,
+		timeout: cfg.Timeout,
+		c:       make(chan IQBatch, cfg.Buffer),
+		ctx:     ctx,
+	}
 	iq.mu.Lock()
 	defer iq.mu.Unlock()
 	iq.chans[iqc] = struct{}{}
 	if len(iq.chans) == 1 {
-		iq.batch = batch
 		go iq.dispatch()
-	} else if iq.batch != batch {
-		panic("bad batch")
 	}
-	return iqc.c
+	return &IQStream{C: iqc.c, iqc: iqc}
 }
 
-func (iq *IQReader) dispatch() error {
-	iq8buf := make([]byte, iq.batch*2)
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-	for {
-		sumBytes := 0
-		for sumBytes != len(iq8buf) {
-			readBytes := 0
-			if readBytes, iq.err = iq.r.Read(iq8buf[sumBytes:]); iq.err != nil {
-				iq.mu.Lock()
-				defer iq.mu.Unlock()
-				for iqc := range iq.chans {
-					close(iqc.c)
+func (s *IQStream) Stats() IQStats {
+	return IQStats{
+		Samples:  s.iqc.samples.Load(),
+		Dropped:  s.iqc.drops.Load(),
+		Overruns: s.iqc.overruns.Load(),
+	}
+}
+
+// Samples strips the stream down to its samples.
+func (s *IQStream) Samples(ctx context.Context) <-chan []complex64 {
+	sampc := make(chan []complex64)
+	go func() {
+		defer close(sampc)
+		for b := range s.C {
+			select {
+			case sampc <- b.Samples:
+			case <-ctx.Done():
+				// Drain so the dispatcher can close the stream.
+				for range s.C {
 				}
-				iq.chans = make(map[*iqChannel]struct{})
-				return iq.err
+				return
 			}
-			sumBytes += readBytes
 		}
+	}()
+	return sampc
+}
+
+// readBlock is the most samples read at once. Reads take whatever the radio
+// has sent so far; send rechunks them for each subscriber.
+const readBlock = 16384
+
+func (iq *IQReader) subscribers() int {
+	iq.mu.Lock()
+	defer iq.mu.Unlock()
+	return len(iq.chans)
+}
+
+var closedc = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// How long a subscriber sharing a reader may hold up the others, and by
+// default how long it may then drop samples before it is disconnected.
+const stallTimeout = time.Second
 
-		samps := make([]complex64, iq.batch)
-		for i := 0; i < len(samps); i++ {
-			samps[i] = complex(
-				(float32(iq8buf[2*i])-127)/128.0,
-				(float32(iq8buf[2*i+1])-127)/128.0)
+func (iq *IQReader) dispatch() error {
+	sampBytes := iq.format.SampleBytes()
+	iq8buf := make([]byte, readBlock*sampBytes)
+	// held is the bytes read past the last whole sample.
+	held := 0
+	for {
+		if iq.subscribers() == 0 {
+			return nil
+		}
+		readBytes := 0
+		if readBytes, iq.err = iq.r.Read(iq8buf[held:]); iq.err != nil {
+			iq.mu.Lock()
+			defer iq.mu.Unlock()
+			for iqc := range iq.chans {
+				close(iqc.c)
+			}
+			iq.chans = make(map[*iqChannel]struct{})
+			return iq.err
+		}
+		held += readBytes
+		n := held / sampBytes
+		if n == 0 {
+			continue
 		}
 
-		ticker.Reset(time.Second)
-		for len(ticker.C) > 0 {
-			<-ticker.C
+		b := IQBatch{Samples: make([]complex64, n), Index: iq.index, Time: time.Now()}
+		iq.format.decode(b.Samples, iq8buf[:n*sampBytes])
+		held = copy(iq8buf, iq8buf[n*sampBytes:held])
+		iq.index += uint64(n)
+		if rate := iq.rate.Load(); rate != 0 {
+			b.Time = b.Time.Add(-time.Duration(float64(n) / float64(rate) * float64(time.Second)))
 		}
 
 		iq.mu.Lock()
-
-		tc := ticker.C
-		if len(iq.chans) == 1 {
-			tc = nil
+		// A lone subscriber sets the pace; otherwise subscribers that
+		// fall behind lose samples instead of holding up the others.
+		var expiredc chan struct{}
+		var timer *time.Timer
+		if len(iq.chans) > 1 {
+			expiredc = make(chan struct{})
+			timer = time.AfterFunc(stallTimeout, func() { close(expiredc) })
 		}
-
-		// Broadcast.
 		for iqc := range iq.chans {
-			ok := false
-			select {
-			case iqc.c <- samps:
-				iqc.sent++
-				ok = iqc.limit == 0 || iqc.sent < iqc.limit
-			case <-iqc.ctx.Done():
-				log.Println("canceled channel")
-			case <-tc:
-				log.Println("channel too slow")
-			}
-			if !ok {
+			if !iqc.send(b, expiredc, iq.rate.Load()) {
 				delete(iq.chans, iqc)
 				close(iqc.c)
 			}
 		}
+		if timer != nil {
+			timer.Stop()
+		}
 		if len(iq.chans) == 0 {
 			// TODO: close reader entirely, reopen when ready
 			iq.mu.Unlock()
@@ -131,17 +464,162 @@ func (iq *IQReader) dispatch() error {
 	}
 }
 
-type IQWriter struct{ w io.Writer }
+// send rechunks b into the channel's batch size, returning false if the
+// channel should be closed.
+func (iqc *iqChannel) send(b IQBatch, expiredc <-chan struct{}, rate uint64) bool {
+	if len(iqc.pending) == 0 && len(b.Samples) == iqc.batch {
+		// Common case; share the batch with other subscribers.
+		return iqc.sendBatch(b, expiredc)
+	}
+	if len(iqc.pending) == 0 {
+		iqc.pendingIndex, iqc.pendingTime = b.Index, b.Time
+	}
+	iqc.pending = append(iqc.pending, b.Samples...)
+	for len(iqc.pending) >= iqc.batch {
+		out := IQBatch{
+			Samples: make([]complex64, iqc.batch),
+			Index:   iqc.pendingIndex,
+			Time:    iqc.pendingTime,
+		}
+		copy(out.Samples, iqc.pending)
+		iqc.pending = iqc.pending[iqc.batch:]
+		iqc.pendingIndex += uint64(iqc.batch)
+		if rate != 0 {
+			iqc.pendingTime = iqc.pendingTime.Add(time.Duration(float64(iqc.batch) / float64(rate) * float64(time.Second)))
+		}
+		if !iqc.sendBatch(out, expiredc) {
+			return false
+		}
+	}
+	if len(iqc.pending) == 0 {
+		iqc.pending = nil
+	}
+	return true
+}
 
-func NewIQWriter(w io.Writer) *IQWriter { return &IQWriter{w} }
+// sendBatch delivers the batch according to the channel's backpressure
+// policy. Disconnect subscribers block until the batch is sent or, for
+// shared readers, until expiredc closes; one that misses the deadline drops
+// batches until it catches up or is closed after its timeout.
+func (iqc *iqChannel) sendBatch(b IQBatch, expiredc <-chan struct{}) bool {
+	b.Dropped = iqc.dropped
+	if iqc.ctx.Err() != nil {
+		// A canceled subscriber may still be draining its channel.
+		log.Println("canceled channel")
+		return false
+	}
+	// Deliver whenever there's room, even if the deadline has passed.
+	select {
+	case iqc.c <- b:
+		return iqc.delivered(b)
+	default:
+	}
+	waitc := expiredc
+	switch {
+	case iqc.policy == BackpressureDropOldest:
+		return iqc.dropOldest(b)
+	case iqc.policy == BackpressureBlock:
+		waitc = nil
+	case waitc != nil && !iqc.stalled.IsZero():
+		// Already behind; don't hold up the others.
+		waitc = closedc
+	}
+	select {
+	case iqc.c <- b:
+		return iqc.delivered(b)
+	case <-iqc.ctx.Done():
+		log.Println("canceled channel")
+		return false
+	case <-waitc:
+	}
+	now := time.Now()
+	if iqc.stalled.IsZero() {
+		iqc.stalled = now
+		iqc.overruns.Add(1)
+	} else if now.Sub(iqc.stalled) > iqc.timeout {
+		log.Println("channel too slow")
+		return false
+	}
+	iqc.dropped += uint64(len(b.Samples))
+	iqc.drops.Add(uint64(len(b.Samples)))
+	return true
+}
 
-func (iq *IQWriter) Write64(out []complex64) error {
-	buf := make([]byte, 2*len(out))
-	for i := range out {
-		// Convert to u8.
-		buf[2*i] = byte((real(out[i]) * 128.0) + 127.0)
-		buf[2*i+1] = byte((imag(out[i]) * 128.0) + 127.0)
+// dropOldest queues b in place of the oldest queued batch if the queue is
+// still full. The queue is cycled through so the batch after the dropped one
+// reports the gap.
+func (iqc *iqChannel) dropOldest(b IQBatch) bool {
+	if iqc.ctx.Err() != nil {
+		log.Println("canceled channel")
+		return false
 	}
+	// The reader may have caught up since the last try.
+	select {
+	case iqc.c <- b:
+		return iqc.delivered(b)
+	default:
+	}
+	var q []IQBatch
+	for len(q) < cap(iqc.c) {
+		select {
+		case qb := <-iqc.c:
+			q = append(q, qb)
+			continue
+		default:
+		}
+		break
+	}
+	if len(q) > 0 {
+		if iqc.stalled.IsZero() {
+			iqc.stalled = time.Now()
+			iqc.overruns.Add(1)
+		}
+		n := uint64(len(q[0].Samples))
+		lost := q[0].Dropped + n
+		// Queued batches were counted when sent.
+		iqc.samples.Add(-n)
+		iqc.drops.Add(n)
+		if q = q[1:]; len(q) > 0 {
+			q[0].Dropped += lost
+		} else {
+			b.Dropped += lost
+		}
+	}
+	// The dispatcher is the only sender so there's room for everything.
+	for _, qb := range q {
+		iqc.c <- qb
+	}
+	iqc.c <- b
+	iqc.samples.Add(uint64(len(b.Samples)))
+	iqc.sent++
+	return iqc.limit == 0 || iqc.sent < iqc.limit
+}
+
+func (iqc *iqChannel) delivered(b IQBatch) bool {
+	iqc.stalled, iqc.dropped = time.Time{}, 0
+	iqc.samples.Add(uint64(len(b.Samples)))
+	iqc.sent++
+	return iqc.limit == 0 || iqc.sent < iqc.limit
+}
+
+type IQWriter struct {
+	w      io.Writer
+	format SampleFormat
+}
+
+// NewIQWriter takes a writer for u8 I/Q samples.
+func NewIQWriter(w io.Writer) *IQWriter { return NewIQWriterFormat(w, FormatCU8) }
+
+// NewIQWriterFormat takes a writer for I/Q samples encoded by f.
+func NewIQWriterFormat(w io.Writer, f SampleFormat) *IQWriter {
+	return &IQWriter{w: w, format: f}
+}
+
+func (iq *IQWriter) Format() SampleFormat { return iq.format }
+
+func (iq *IQWriter) Write64(out []complex64) error {
+	buf := make([]byte, iq.format.SampleBytes()*len(out))
+	iq.format.encode(buf, out)
 	_, err := iq.w.Write(buf)
 	return err
 }
@@ -0,0 +1,270 @@
+package radio
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSampleFormats(t *testing.T) {
+	in := []complex64{0, complex(0.5, -0.5), complex(-1, 0.25), complex(0.99, -0.99)}
+	tests := []struct {
+		f   SampleFormat
+		tol float32
+	}{
+		{FormatCU8, 1.0 / 64},
+		{FormatCS8, 1.0 / 64},
+		{FormatCS16LE, 1.0 / 16384},
+		{FormatCF32LE, 0},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewIQWriterFormat(&buf, tt.f).Write64(in); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != len(in)*tt.f.SampleBytes() {
+			t.Fatalf("%s: wrote %d bytes, expected %d", tt.f, buf.Len(), len(in)*tt.f.SampleBytes())
+		}
+		out := <-NewIQReaderFormat(&buf, tt.f).Batch64(len(in), 1)
+		for i := range in {
+			d := out[i] - in[i]
+			if real(d) > tt.tol || real(d) < -tt.tol || imag(d) > tt.tol || imag(d) < -tt.tol {
+				t.Errorf("%s: sample %d read %v, wrote %v", tt.f, i, out[i], in[i])
+			}
+		}
+	}
+}
+
+func TestBatchSizes(t *testing.T) {
+	batches := []int{64, 7, 512}
+	// Every subscriber reads n samples; the extra samples let the final
+	// reads complete regardless of read size.
+	const n = 64 * 7 * 512
+	in := make([]complex64, n+512)
+	for i := range in {
+		in[i] = complex(float32(i), 0)
+	}
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	iqr := NewIQReaderFormat(pr, FormatCF32LE)
+	var cs []<-chan []complex64
+	for _, b := range batches {
+		cs = append(cs, iqr.BatchStream64(context.TODO(), b, n/b))
+	}
+	go NewIQWriterFormat(pw, FormatCF32LE).Write64(in)
+
+	var wg sync.WaitGroup
+	for i, c := range cs {
+		wg.Add(1)
+		go func(batch int, c <-chan []complex64) {
+			defer wg.Done()
+			next := 0
+			for samps := range c {
+				if len(samps) != batch {
+					t.Errorf("got batch %d, expected %d", len(samps), batch)
+				}
+				for _, v := range samps {
+					if int(real(v)) != next {
+						t.Errorf("batch %d: got sample %v, expected %d", batch, v, next)
+						return
+					}
+					next++
+				}
+			}
+			if next != n {
+				t.Errorf("batch %d: got %d samples, expected %d", batch, next, n)
+			}
+		}(batches[i], c)
+	}
+	wg.Wait()
+}
+
+func TestStreamOverrun(t *testing.T) {
+	const batch, batches = 100, 20
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	iqr := NewIQReaderFormat(pr, FormatCF32LE).ToMixer(HzBand{Width: 1000})
+	fast := iqr.Subscribe(context.TODO(), batch, batches)
+	slow := iqr.Subscribe(context.TODO(), batch, 0)
+	// Write a batch each time the fast reader is ready so only the slow
+	// reader falls behind.
+	stepc := make(chan struct{}, 1)
+	stepc <- struct{}{}
+	go func() {
+		iqw := NewIQWriterFormat(pw, FormatCF32LE)
+		// One more batch for the slow reader after the fast one leaves.
+		for i := 0; i <= batches; i++ {
+			<-stepc
+			if iqw.Write64(make([]complex64, batch)) != nil {
+				return
+			}
+		}
+	}()
+
+	next := uint64(0)
+	for b := range fast.C {
+		if b.Index != next || b.Dropped != 0 {
+			t.Fatalf("fast: got index %d dropped %d, expected %d", b.Index, b.Dropped, next)
+		}
+		next += uint64(len(b.Samples))
+		stepc <- struct{}{}
+	}
+	if st := fast.Stats(); st.Samples != batch*batches || st.Dropped != 0 {
+		t.Fatalf("fast: got stats %+v", st)
+	}
+
+	st := slow.Stats()
+	if st.Overruns != 1 || st.Dropped == 0 || st.Samples+st.Dropped != batch*batches {
+		t.Fatalf("slow: got stats %+v", st)
+	}
+	// The gap follows the buffered batches.
+	var gap IQBatch
+	for i := 0; i <= cap(slow.C); i++ {
+		gap = <-slow.C
+	}
+	if gap.Dropped != st.Dropped || gap.Index != uint64(cap(slow.C))*batch+st.Dropped {
+		t.Fatalf("slow: got batch index %d dropped %d, stats %+v", gap.Index, gap.Dropped, st)
+	}
+}
+
+func TestBackpressure(t *testing.T) {
+	const batch, batches, buffer = 100, 10, 2
+	var buf bytes.Buffer
+	if err := NewIQWriterFormat(&buf, FormatCF32LE).Write64(make([]complex64, batch*batches)); err != nil {
+		t.Fatal(err)
+	}
+	iqr := NewIQReaderFormat(&buf, FormatCF32LE)
+	lossy := iqr.SubscribeConfig(context.TODO(), StreamConfig{
+		Batch:        batch,
+		Backpressure: BackpressureDropOldest,
+		Buffer:       buffer,
+	})
+	lossless := iqr.SubscribeConfig(context.TODO(), StreamConfig{
+		Batch:        batch,
+		Backpressure: BackpressureBlock,
+	})
+
+	next := uint64(0)
+	for b := range lossless.C {
+		if b.Index != next || b.Dropped != 0 {
+			t.Fatalf("block: got index %d dropped %d, expected %d", b.Index, b.Dropped, next)
+		}
+		next += uint64(len(b.Samples))
+	}
+	if next != batch*batches {
+		t.Fatalf("block: got %d samples, expected %d", next, batch*batches)
+	}
+
+	// Only the newest batches are left.
+	if st := lossy.Stats(); st.Samples != batch*buffer || st.Dropped != batch*(batches-buffer) || st.Overruns != 1 {
+		t.Fatalf("drop_oldest: got stats %+v", st)
+	}
+	next = batch * (batches - buffer)
+	for b := range lossy.C {
+		// The first batch reports everything dropped before it.
+		dropped := uint64(0)
+		if next == batch*(batches-buffer) {
+			dropped = next
+		}
+		if b.Index != next || b.Dropped != dropped {
+			t.Fatalf("drop_oldest: got index %d dropped %d, expected %d dropped %d", b.Index, b.Dropped, next, dropped)
+		}
+		next += uint64(len(b.Samples))
+	}
+	if next != batch*batches {
+		t.Fatalf("drop_oldest: ended at %d, expected %d", next, batch*batches)
+	}
+}
+
+// pacedReader reads at most every millisecond, like a radio.
+type pacedReader struct{ io.Reader }
+
+func (r pacedReader) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	return r.Reader.Read(p)
+}
+
+func TestStreamCancel(t *testing.T) {
+	const n = 1000
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	iqr := NewIQReaderFormat(pacedReader{pr}, FormatCF32LE)
+	live := iqr.BatchStream64(context.TODO(), 100, 0)
+	ctx, cancel := context.WithCancel(context.TODO())
+	iqr.BatchStream64(ctx, 10, 0)
+	cancel()
+	// Extra samples let live finish its last batch after any read size.
+	go NewIQWriterFormat(pw, FormatCF32LE).Write64(make([]complex64, n+200))
+	for got := 0; got < n; {
+		got += len(<-live)
+	}
+	// The dispatcher now waits on the pipe; the canceled subscriber
+	// should have left when it was sent its first batch.
+	iqr.mu.Lock()
+	subs := len(iqr.chans)
+	iqr.mu.Unlock()
+	if subs != 1 {
+		t.Fatalf("got %d subscribers, expected 1", subs)
+	}
+}
+
+// countReader counts its reads.
+type countReader struct {
+	io.Reader
+	reads int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	r.reads++
+	return r.Reader.Read(p)
+}
+
+func TestReadBlock(t *testing.T) {
+	const n = 4 * readBlock
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	cr := &countReader{Reader: pr}
+	iqr := NewIQReaderFormat(cr, FormatCF32LE)
+	tiny := iqr.BatchStream64(context.TODO(), 1, n)
+	big := iqr.BatchStream64(context.TODO(), readBlock, 4)
+	go NewIQWriterFormat(pw, FormatCF32LE).Write64(make([]complex64, n))
+	donec := make(chan int)
+	go func() {
+		got := 0
+		for samps := range tiny {
+			got += len(samps)
+		}
+		donec <- got
+	}()
+	got := 0
+	for samps := range big {
+		got += len(samps)
+	}
+	if tinyGot := <-donec; got != n || tinyGot != n {
+		t.Fatalf("got %d and %d samples, expected %d", got, tinyGot, n)
+	}
+	// A subscriber with a tiny batch doesn't shrink the reads.
+	if cr.reads > 2*n/readBlock {
+		t.Fatalf("%d reads for %d samples", cr.reads, n)
+	}
+}
+
+func TestDropOldestRoom(t *testing.T) {
+	iqc := &iqChannel{batch: 1, policy: BackpressureDropOldest, c: make(chan IQBatch, 2), ctx: context.TODO()}
+	iqc.c <- IQBatch{Samples: make([]complex64, 1)}
+	// The reader freed a slot since the batch missed the queue.
+	if !iqc.dropOldest(IQBatch{Samples: make([]complex64, 1), Index: 1}) {
+		t.Fatal("channel closed")
+	}
+	if d := iqc.drops.Load(); d != 0 || len(iqc.c) != 2 {
+		t.Fatalf("dropped %d with %d queued, expected nothing dropped", d, len(iqc.c))
+	}
+	for i := uint64(0); i < 2; i++ {
+		if b := <-iqc.c; b.Index != i {
+			t.Fatalf("got batch %d, expected %d", b.Index, i)
+		}
+	}
+}
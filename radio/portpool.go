@@ -0,0 +1,55 @@
+package radio
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+)
+
+var ErrNoPorts = errors.New("no free ports")
+
+// portPool hands out local ports for rtl_tcp children, skipping ports
+// that are still held by a child or by anything else on the host.
+type portPool struct {
+	base, size int
+	next       int
+	used       map[int]struct{}
+	mu         sync.Mutex
+}
+
+const portBase = 12345
+
+var rtlPorts = newPortPool(portBase, 64)
+
+func newPortPool(base, size int) *portPool {
+	return &portPool{base: base, size: size, used: make(map[int]struct{})}
+}
+
+// get reserves a port that is free to listen on.
+func (pp *portPool) get() (int, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	// Round robin so a port isn't reused right after it is released.
+	for i := 0; i < pp.size; i++ {
+		p := pp.base + (pp.next+i)%pp.size
+		if _, ok := pp.used[p]; ok {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(p)))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		pp.used[p] = struct{}{}
+		pp.next = (pp.next + i + 1) % pp.size
+		return p, nil
+	}
+	return 0, ErrNoPorts
+}
+
+func (pp *portPool) put(p int) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	delete(pp.used, p)
+}
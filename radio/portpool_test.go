@@ -0,0 +1,45 @@
+package radio
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestPortPool(t *testing.T) {
+	// Hold the first port so the pool has to skip it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	base := l.Addr().(*net.TCPAddr).Port
+	if base+3 > 65535 {
+		t.Skip("no room for pool after", base)
+	}
+	pp := newPortPool(base, 3)
+
+	p1, err := pp.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := pp.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p1 == base || p2 == base || p1 == p2 {
+		t.Fatalf("got ports %d and %d with %d in use", p1, p2, base)
+	}
+	if p, err := pp.get(); err != ErrNoPorts {
+		t.Fatalf("expected no ports, got %d, %v", p, err)
+	}
+	pp.put(p1)
+	if p, err := pp.get(); err != nil || p != p1 {
+		t.Fatalf("expected released port %d, got %d, %v", p1, p, err)
+	}
+	l2, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(p2))
+	if err != nil {
+		t.Fatalf("reserved ports should be free to listen on: %v", err)
+	}
+	l2.Close()
+}
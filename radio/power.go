@@ -1,25 +1,71 @@
 package radio
 
 import (
+	"fmt"
 	"io"
 	"math"
-	"math/cmplx"
-	"runtime"
 	"sort"
+	"strings"
 
-	"github.com/runningwild/go-fftw/fftw32"
+	"github.com/chzchzchz/nicerx/dsp/fft"
 )
 
+// Averaging selects how FFTs are combined into the average spectrum.
+type Averaging string
+
+const (
+	// AverageMean is the Welch estimate, the mean power of every FFT.
+	AverageMean Averaging = "mean"
+	// AverageExp decays older FFTs, carrying over between measurements.
+	AverageExp Averaging = "exp"
+	// AveragePeak holds the highest power seen in each bin.
+	AveragePeak Averaging = "peak"
+)
+
+func ParseAveraging(s string) (Averaging, error) {
+	switch a := Averaging(strings.ToLower(s)); a {
+	case "":
+		return AverageMean, nil
+	case AverageMean, AverageExp, AveragePeak:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown averaging %q", s)
+}
+
+// SpectralConfig sets up the power spectral density estimate.
+type SpectralConfig struct {
+	Window Window
+	// Overlap is the fraction of each FFT shared with the next, in [0, 1).
+	Overlap   float64
+	Averaging Averaging
+	// Alpha is the weight of each new FFT with exponential averaging.
+	Alpha float64
+}
+
+// DefaultSpectralConfig is a Welch estimate with 50% overlapped Hann windows.
+var DefaultSpectralConfig = SpectralConfig{Window: WindowHann, Overlap: 0.5, Averaging: AverageMean, Alpha: 0.1}
+
+// SpectralPower estimates the power spectral density of a band in dBFS/Hz,
+// so levels are comparable across bin counts and sample rates.
 type SpectralPower struct {
 	min     []float64
 	max     []float64
 	avg     []float64
 	med     []float64
-	in      *fftw32.Array
-	fftBins *fftw32.Array
+	plan    fft.FFT
+	in      []complex64
+	fftBins []complex64
 	ffts    int
 	band    FreqBand
-	plan    *fftw32.Plan
+
+	cfg    SpectralConfig
+	window []float64
+	// scale converts |X|^2 to power per Hz.
+	scale float64
+	// expLin is the exponential average in linear power.
+	expLin []float64
+	// looks is the number of independent FFTs the average is worth.
+	looks float64
 }
 
 type binBand struct {
@@ -29,21 +75,100 @@ type binBand struct {
 }
 
 func NewSpectralPower(band FreqBand, bins, ffts int) *SpectralPower {
-	// Plans bind pointers forever, so need to copy into 'in' later.
-	in, out := fftw32.NewArray(bins), fftw32.NewArray(bins)
-	plan := fftw32.NewPlan(in, out, fftw32.Forward, fftw32.DefaultFlag)
-	runtime.SetFinalizer(plan, func(p *fftw32.Plan) { p.Destroy() })
-	return &SpectralPower{
-		in:      in,
-		fftBins: out,
+	return NewSpectralPowerConfig(band, bins, ffts, DefaultSpectralConfig)
+}
+
+// NewSpectralPowerConfig measures ffts blocks of bins samples at a time.
+// Overlapping windows take more FFTs from the same samples.
+func NewSpectralPowerConfig(band FreqBand, bins, ffts int, cfg SpectralConfig) *SpectralPower {
+	if cfg.Window == "" {
+		cfg.Window = DefaultSpectralConfig.Window
+	}
+	if cfg.Averaging == "" {
+		cfg.Averaging = DefaultSpectralConfig.Averaging
+	}
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = DefaultSpectralConfig.Alpha
+	}
+	cfg.Overlap = math.Max(0, math.Min(cfg.Overlap, 0.95))
+	w, wss := cfg.Window.Coeffs(bins), 0.0
+	for _, v := range w {
+		wss += v * v
+	}
+	sp := &SpectralPower{
+		plan:    fft.New(bins),
+		in:      make([]complex64, bins),
+		fftBins: make([]complex64, bins),
 		ffts:    ffts,
 		band:    band,
-		plan:    plan,
+		cfg:     cfg,
+		window:  w,
+		scale:   1 / (band.Width * 1e6 * wss),
+	}
+	sp.looks = sp.equivalentLooks()
+	return sp
+}
+
+func (sp *SpectralPower) hop() int {
+	return max(1, int(float64(len(sp.window))*(1-sp.cfg.Overlap)))
+}
+
+func (sp *SpectralPower) segments() int {
+	bins := len(sp.window)
+	return (sp.ffts*bins-bins)/sp.hop() + 1
+}
+
+// equivalentLooks is how many independent FFTs give the same variance as
+// the average, following Welch: overlapped FFTs are correlated by the
+// square of their windows' overlap.
+func (sp *SpectralPower) equivalentLooks() float64 {
+	w, hop := sp.window, sp.hop()
+	wss := 0.0
+	for _, v := range w {
+		wss += v * v
+	}
+	// rho is the correlation of FFTs k hops apart.
+	rho := func(k int) float64 {
+		c := 0.0
+		for i := 0; i+k*hop < len(w); i++ {
+			c += w[i] * w[i+k*hop]
+		}
+		return (c / wss) * (c / wss)
 	}
+	segs := sp.segments()
+	switch sp.cfg.Averaging {
+	case AveragePeak:
+		// The maximum isn't an average; treat it as a single FFT.
+		return 1
+	case AverageExp:
+		a := sp.cfg.Alpha
+		sum := 1.0
+		for k := 1; k*hop < len(w); k++ {
+			sum += 2 * rho(k) * math.Pow(1-a, float64(k))
+		}
+		return math.Max(1, (2-a)/a/sum)
+	}
+	sum := 1.0
+	for k := 1; k < segs && k*hop < len(w); k++ {
+		sum += 2 * (1 - float64(k)/float64(segs)) * rho(k)
+	}
+	return math.Max(1, float64(segs)/sum)
 }
 
+// Looks is the number of independent FFTs the average spectrum is worth.
+// Its noise is gamma distributed with this shape, which is exponential
+// for a single FFT.
+func (sp *SpectralPower) Looks() float64 { return sp.looks }
+
+// Reset clears the exponential average.
+func (sp *SpectralPower) Reset() { sp.expLin = nil }
+
+// Average is the averaged power of each bin in dBFS/Hz.
 func (sp *SpectralPower) Average() []float64 { return sp.avg }
 
+// Max is the peak power of each bin in dBFS/Hz, for finding bursts.
+func (sp *SpectralPower) Max() []float64 { return sp.max }
+
 func (sp *SpectralPower) NoiseFloor() float64 {
 	med := make([]float64, len(sp.med))
 	copy(med, sp.med)
@@ -81,42 +206,13 @@ func (sp *SpectralPower) Spurs() (ret []FreqBand) {
 	return ret
 }
 
-func (sp *SpectralPower) Bands() (ret []FreqBand) {
-	spr, sdev := sp.Spread(), sp.Stddev()
-	begin, end := -1, -1
-	db := 0.0
-	for i, avg := range sp.avg {
-		if avg-spr >= 1.5*sdev {
-			if begin == -1 {
-				if i == 0 || sp.avg[i-1]-spr > (avg-spr)/2.0 {
-					continue
-				}
-				begin = i
-			}
-			end = i
-			db += avg - spr
-		} else if begin != -1 {
-			n := end - begin + 1
-			bb := binBand{begin, (end - begin) + 1, db / float64(n)}
-			ret = append(ret, sp.freq(bb))
-			begin, db = -1, 0
-		}
-	}
-	if begin != -1 {
-		n := end - begin + 1
-		bb := binBand{begin, (end - begin) + 1, db / float64(n)}
-		ret = append(ret, sp.freq(bb))
-	}
-	return ret
-}
-
 func (sp *SpectralPower) binMHz() float64 {
-	bins := len(sp.fftBins.Elems)
+	bins := len(sp.fftBins)
 	return sp.band.Width / float64(bins)
 }
 
 func (sp *SpectralPower) BandPower(fb FreqBand, samps int) float64 {
-	bins := len(sp.fftBins.Elems)
+	bins := len(sp.fftBins)
 	bandBins := int(fb.Width / sp.binMHz())
 	startOffMHz := fb.BeginMHz() - sp.band.Center
 	startBin := int(startOffMHz/sp.binMHz() + float64(bins/2))
@@ -128,51 +224,86 @@ func (sp *SpectralPower) BandPower(fb FreqBand, samps int) float64 {
 }
 
 func (sp *SpectralPower) freq(bb binBand) FreqBand {
-	beginMHz := float64(bb.Begin-len(sp.fftBins.Elems)/2)*sp.binMHz() + sp.band.Center
+	beginMHz := float64(bb.Begin-len(sp.fftBins)/2)*sp.binMHz() + sp.band.Center
 	bw := float64(bb.Bins) * sp.binMHz()
 	return FreqBand{Center: beginMHz + bw/2.0, Width: bw}
 }
 
+// Measure reads ffts blocks from ch and estimates the spectrum.
 func (sp *SpectralPower) Measure(ch <-chan []complex64) error {
-	sp.min = make([]float64, len(sp.fftBins.Elems))
-	sp.max = make([]float64, len(sp.fftBins.Elems))
-	sp.avg = make([]float64, len(sp.fftBins.Elems))
-	sp.med = make([]float64, len(sp.fftBins.Elems))
-	meds := make([][]float64, len(sp.fftBins.Elems))
+	bins, hop, segs := len(sp.fftBins), sp.hop(), sp.segments()
+	sp.min = make([]float64, bins)
+	sp.max = make([]float64, bins)
+	sp.avg = make([]float64, bins)
+	sp.med = make([]float64, bins)
+	sumLin := make([]float64, bins)
+	if sp.expLin == nil {
+		sp.expLin = make([]float64, bins)
+	}
+	for i := range sp.min {
+		sp.min[i], sp.max[i] = math.Inf(1), math.Inf(-1)
+	}
+	meds := make([][]float64, bins)
 	medSamples := 10
-	if medSamples > sp.ffts {
-		medSamples = sp.ffts
+	if medSamples > segs {
+		medSamples = segs
 	}
 	for i := range meds {
 		meds[i] = make([]float64, medSamples)
 	}
 
-	for n := 0; n < sp.ffts; n++ {
+	buf := make([]complex64, 0, 2*bins)
+	for n, seg := 0, 0; n < sp.ffts; n++ {
 		samps, ok := <-ch
 		if !ok {
 			return io.EOF
 		}
-		copy(sp.in.Elems, samps)
-		sp.plan.Execute()
-		for i, v := range sp.fftBins.Elems {
-			idx := i + len(sp.fftBins.Elems)/2
-			if i >= len(sp.fftBins.Elems)/2 {
-				idx = i - len(sp.fftBins.Elems)/2
+		buf = append(buf, samps...)
+		for ; len(buf) >= bins && seg < segs; seg++ {
+			for i, v := range buf[:bins] {
+				w := float32(sp.window[i])
+				sp.in[i] = complex(real(v)*w, imag(v)*w)
 			}
-			db := 20 * math.Log10(cmplx.Abs(complex128(v)))
-			sp.avg[idx] += db / float64(sp.ffts)
-			if sp.min[idx] == 0 || sp.min[idx] > db {
-				sp.min[idx] = db
-			}
-			if sp.max[idx] == 0 || sp.max[idx] < db {
-				sp.max[idx] = db
-			}
-			meds[idx][((len(meds[idx])-1)*n)/sp.ffts] = db
+			buf = buf[:copy(buf, buf[hop:])]
+			sp.plan.Transform(sp.fftBins, sp.in)
+			sp.accumulate(seg, segs, sumLin, meds)
 		}
 	}
-	for i := range sp.med {
+	for i := range sp.avg {
+		switch sp.cfg.Averaging {
+		case AverageExp:
+			sp.avg[i] = powerDB(sp.expLin[i])
+		case AveragePeak:
+			sp.avg[i] = sp.max[i]
+		default:
+			sp.avg[i] = powerDB(sumLin[i] / float64(segs))
+		}
 		sort.Float64s(meds[i])
 		sp.med[i] = meds[i][len(meds[i])/2]
 	}
 	return nil
 }
+
+// accumulate adds the last FFT, centering DC, into the running estimates.
+func (sp *SpectralPower) accumulate(seg, segs int, sumLin []float64, meds [][]float64) {
+	bins := len(sp.fftBins)
+	for i, v := range sp.fftBins {
+		idx := i + bins/2
+		if i >= bins/2 {
+			idx = i - bins/2
+		}
+		lin := (float64(real(v))*float64(real(v)) + float64(imag(v))*float64(imag(v))) * sp.scale
+		db := powerDB(lin)
+		sumLin[idx] += lin
+		if sp.expLin[idx] == 0 {
+			sp.expLin[idx] = lin
+		} else {
+			sp.expLin[idx] += sp.cfg.Alpha * (lin - sp.expLin[idx])
+		}
+		sp.min[idx] = math.Min(sp.min[idx], db)
+		sp.max[idx] = math.Max(sp.max[idx], db)
+		meds[idx][((len(meds[idx])-1)*seg)/segs] = db
+	}
+}
+
+func powerDB(lin float64) float64 { return 10 * math.Log10(lin+1e-30) }
@@ -0,0 +1,61 @@
+package radio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noiseBatches is complex white noise with total power pwr.
+func noiseBatches(rng *rand.Rand, bins, n int, pwr float64) <-chan []complex64 {
+	ch := make(chan []complex64, n)
+	sd := math.Sqrt(pwr / 2)
+	for i := 0; i < n; i++ {
+		b := make([]complex64, bins)
+		for j := range b {
+			b[j] = complex(float32(rng.NormFloat64()*sd), float32(rng.NormFloat64()*sd))
+		}
+		ch <- b
+	}
+	close(ch)
+	return ch
+}
+
+func TestSpectralPowerDBFS(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	band := HzBand{Center: 100000000, Width: 1024000}
+	// -30dBFS of noise over 1.024MHz.
+	want := -30 - 10*math.Log10(float64(band.Width))
+	for _, w := range []Window{WindowRect, WindowHann, WindowBlackmanHarris, WindowFlatTop} {
+		for _, bins := range []int{256, 4096} {
+			cfg := SpectralConfig{Window: w, Overlap: 0.5}
+			sp := NewSpectralPowerConfig(band.ToMHz(), bins, 50, cfg)
+			if err := sp.Measure(noiseBatches(rng, bins, 50, 1e-3)); err != nil {
+				t.Fatal(err)
+			}
+			if got := sp.Spread(); math.Abs(got-want) > 0.5 {
+				t.Errorf("%s/%d: noise at %.2fdBFS/Hz, expected %.2f", w, bins, got, want)
+			}
+		}
+	}
+}
+
+func TestSpectralPowerAveraging(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	band := HzBand{Center: 100000000, Width: 1024000}
+	bins := 1024
+	meas := func(a Averaging) float64 {
+		sp := NewSpectralPowerConfig(band.ToMHz(), bins, 20, SpectralConfig{Averaging: a})
+		if err := sp.Measure(noiseBatches(rng, bins, 20, 1e-3)); err != nil {
+			t.Fatal(err)
+		}
+		return sp.Spread()
+	}
+	mean, exp, peak := meas(AverageMean), meas(AverageExp), meas(AveragePeak)
+	if math.Abs(mean-exp) > 2 {
+		t.Errorf("exponential average %.2f far from mean %.2f", exp, mean)
+	}
+	if peak < mean+3 {
+		t.Errorf("peak hold %.2f not above mean %.2f", peak, mean)
+	}
+}
@@ -1,40 +1,268 @@
 package radio
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var ErrNoCalibration = errors.New("calibration did not converge")
+
 const ppmSampleRate = 2048000
 const ppmBuckets = 8192
 const ppmFFTsPerSecond = ppmSampleRate / ppmBuckets
-const ppmBucketMHz = ppmSampleRate / ppmBuckets / 1.0e6
+const ppmBucketHz = float64(ppmSampleRate) / ppmBuckets
 const ppmCenterMHz = 162.0
 
 // Collect 250ms of data.
 const ppmFFTs = ppmFFTsPerSecond / 4
 
+// Largest crystal error to search for; cheap dongles can be off by ~100ppm.
+const ppmMaxError = 150
+
+// Carrier references are tuned this far below to stay clear of the DC spike.
+const ppmTuneOffsetHz = 250000
+
+// Broadcast FM is demodulated at this rate to find the stereo pilot.
+const pilotSampleRate = 240000
+const pilotHz = 19000
+
+// Pilot phase is averaged over 100ms blocks for 3s.
+const pilotBlock = pilotSampleRate / 10
+const pilotBlocks = 30
+
+// FCCH bursts are a tone 1625/24kHz above the GSM carrier.
+const fcchOffsetHz = 1625000.0 / 24
+
+type CalRefType string
+
+const (
+	// CalNOAA uses the strongest NOAA weather radio carrier near 162MHz.
+	CalNOAA CalRefType = "noaa"
+	// CalFMPilot uses the 19kHz stereo pilot of a broadcast FM station,
+	// measuring the sample clock which shares the tuner's crystal.
+	CalFMPilot CalRefType = "fm_pilot"
+	// CalBeacon uses an unmodulated carrier on a known frequency.
+	CalBeacon CalRefType = "beacon"
+	// CalGSM uses the FCCH bursts of a GSM downlink channel.
+	CalGSM CalRefType = "gsm"
+)
+
+// CalReference is a signal with a precisely known frequency.
+type CalReference struct {
+	Type CalRefType `json:"type"`
+	// Hz is the station, beacon, or GSM downlink carrier frequency; unused
+	// for NOAA.
+	Hz uint64 `json:"hz,omitempty"`
+}
+
+var DefaultCalReference = CalReference{Type: CalNOAA}
+
+// ParseCalReference accepts "noaa" or "<type>:<hz>", e.g. "fm_pilot:100100000".
+func ParseCalReference(s string) (ref CalReference, err error) {
+	typ, hz, hasHz := strings.Cut(s, ":")
+	ref.Type = CalRefType(strings.ToLower(typ))
+	if hasHz {
+		if ref.Hz, err = strconv.ParseUint(hz, 10, 64); err != nil {
+			return ref, err
+		}
+	}
+	switch ref.Type {
+	case "":
+		return DefaultCalReference, nil
+	case CalNOAA:
+	case CalFMPilot, CalBeacon, CalGSM:
+		if ref.Hz == 0 {
+			return ref, fmt.Errorf("calibration reference %q needs a frequency", s)
+		}
+	default:
+		return ref, fmt.Errorf("unknown calibration reference %q", s)
+	}
+	return ref, nil
+}
+
+func (ref CalReference) String() string {
+	if ref.Hz == 0 {
+		return string(ref.Type)
+	}
+	return fmt.Sprintf("%s:%d", ref.Type, ref.Hz)
+}
+
+// FindPPM measures the frequency error against NOAA weather radio.
 func FindPPM(sdr SDR) (float64, error) {
+	return MeasurePPM(sdr, DefaultCalReference)
+}
+
+// MeasurePPM retunes the SDR to the reference and measures the crystal
+// error left over after the current correction. Positive errors mean the
+// SDR tunes high.
+func MeasurePPM(sdr SDR, ref CalReference) (float64, error) {
+	switch ref.Type {
+	case CalNOAA:
+		return measureNOAA(sdr)
+	case CalFMPilot:
+		return measurePilot(sdr, ref.Hz)
+	case CalBeacon:
+		return measureCarrier(sdr, float64(ref.Hz), false)
+	case CalGSM:
+		return measureCarrier(sdr, float64(ref.Hz)+fcchOffsetHz, true)
+	}
+	return 0, fmt.Errorf("unknown calibration reference %q", ref.Type)
+}
+
+func measureNOAA(sdr SDR) (float64, error) {
 	b := HzBand{Center: ppmCenterMHz * 1e6, Width: ppmSampleRate}
+	sp, err := measureSpectrum(sdr, b)
+	if err != nil {
+		return 0, err
+	}
+	noaa := []float64{162.4e6, 162.425e6, 162.450e6, 162.475e6,
+		162.500e6, 162.525e6, 162.550e6}
+	topHz, err := peakHz(b, sp.Average(), 162.4e6*(1-ppmMaxError/1e6), 162.55e6*(1+ppmMaxError/1e6))
+	if err != nil {
+		return 0, err
+	}
+	targetHz, df := 0.0, math.Inf(1)
+	for _, f := range noaa {
+		if diff := math.Abs(topHz - f); diff < df {
+			targetHz, df = f, diff
+		}
+	}
+	return 1e6 * (targetHz - topHz) / targetHz, nil
+}
+
+// measureCarrier finds the error of a tone at hz. Bursty tones are found
+// by their peak power instead of their average.
+func measureCarrier(sdr SDR, hz float64, bursty bool) (float64, error) {
+	b := HzBand{Center: uint64(hz) - ppmTuneOffsetHz, Width: ppmSampleRate}
+	sp, err := measureSpectrum(sdr, b)
+	if err != nil {
+		return 0, err
+	}
+	pwr := sp.Average()
+	if bursty {
+		pwr = sp.Max()
+	}
+	spanHz := hz * ppmMaxError / 1e6
+	topHz, err := peakHz(b, pwr, hz-spanHz, hz+spanHz)
+	if err != nil {
+		return 0, err
+	}
+	return 1e6 * (hz - topHz) / hz, nil
+}
+
+func measureSpectrum(sdr SDR, b HzBand) (*SpectralPower, error) {
 	if err := sdr.SetBand(b); err != nil {
+		return nil, err
+	}
+	sp := NewSpectralPower(b.ToMHz(), ppmBuckets, ppmFFTs)
+	if err := sp.Measure(sdr.Reader().Batch64(ppmBuckets, ppmFFTs)); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// peakHz finds the strongest bin between loHz and hiHz, interpolating
+// between bins for fractional ppm.
+func peakHz(b HzBand, pwr []float64, loHz, hiHz float64) (float64, error) {
+	bin := func(hz float64) int {
+		return int(math.Round((hz-float64(b.Center))/ppmBucketHz)) + len(pwr)/2
+	}
+	lo, hi := max(bin(loHz), 1), min(bin(hiHz), len(pwr)-2)
+	if lo > hi {
+		return 0, ErrFrequencyOutOfRange
+	}
+	top := lo
+	for i := lo; i <= hi; i++ {
+		if pwr[i] > pwr[top] {
+			top = i
+		}
+	}
+	// Parabolic fit through the peak and its neighbors.
+	l, c, r := pwr[top-1], pwr[top], pwr[top+1]
+	off := 0.0
+	if d := l - 2*c + r; d != 0 {
+		off = 0.5 * (l - r) / d
+	}
+	return float64(b.Center) + (float64(top-len(pwr)/2)+off)*ppmBucketHz, nil
+}
+
+// measurePilot FM demodulates a station and tracks the phase of its stereo
+// pilot. A fast crystal speeds up the sample clock, so the pilot drifts low.
+func measurePilot(sdr SDR, hz uint64) (float64, error) {
+	if err := sdr.SetBand(HzBand{Center: hz, Width: pilotSampleRate}); err != nil {
 		return 0, err
 	}
-	ppmFB := FreqBand{Center: ppmCenterMHz, Width: float64(ppmSampleRate) / 1e6}
-	sp := NewSpectralPower(ppmFB, ppmBuckets, ppmFFTs)
-	sp.Measure(sdr.Reader().Batch64(ppmBuckets, ppmFFTs))
-	topAvg, topFreq := 0.0, 0.0
-	for i, v := range sp.Average()[ppmBuckets/2+2:] {
-		if v > topAvg {
-			topAvg = v
-			topFreq = ppmCenterMHz + float64(i+2)*ppmBucketMHz
+	ctx, cancel := context.WithTimeout(context.Background(), 2*pilotBlocks*pilotBlock*time.Second/pilotSampleRate)
+	defer cancel()
+	sampc := sdr.Reader().BatchStream64(ctx, pilotBlock, pilotBlocks+1)
+	// Skip the first block to let the tuner settle.
+	if _, ok := <-sampc; !ok {
+		return 0, ctx.Err()
+	}
+	rot := cmplx.Rect(1, -2*math.Pi*pilotHz/pilotSampleRate)
+	lo, prev, last := complex(1, 0), complex64(0), complex128(0)
+	drift, blocks := complex128(0), 0
+	for samps := range sampc {
+		pilot := complex128(0)
+		for _, s := range samps {
+			// FM discriminator output mixed down by the nominal pilot.
+			fm := cmplx.Phase(complex128(s * complex(real(prev), -imag(prev))))
+			pilot += complex(fm, 0) * lo
+			lo *= rot
+			prev = s
 		}
+		lo /= complex(cmplx.Abs(lo), 0)
+		if blocks > 0 {
+			drift += pilot * cmplx.Conj(last)
+		}
+		last = pilot
+		blocks++
 	}
-	targetFreq, df := 0.0, 999999.0
-	noaa := []float64{162.4, 162.425, 162.450, 162.475,
-		162.500, 162.525, 162.550}
-	for _, f := range noaa {
-		if diff := math.Abs(topFreq - f); diff < df {
-			targetFreq, df = f, diff
+	if blocks < pilotBlocks {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, ErrNoCalibration
+	}
+	dfHz := cmplx.Phase(drift) / (2 * math.Pi) * pilotSampleRate / pilotBlock
+	return -1e6 * dfHz / pilotHz, nil
+}
+
+// Calibrate measures against NOAA weather radio and applies the correction.
+func Calibrate(s SDR) error {
+	_, err := CalibrateRef(s, DefaultCalReference)
+	return err
+}
+
+// CalibrateRef measures against the reference until the applied correction
+// is within 1ppm, returning the fractional crystal error.
+func CalibrateRef(s SDR, ref CalReference) (float64, error) {
+	orig := s.Info().PPM
+	applied := float64(orig)
+	for i := 0; i < 3; i++ {
+		residual, err := MeasurePPM(s, ref)
+		if err != nil {
+			return 0, err
 		}
+		ppm := applied + residual
+		log.Printf("measured ppm %.2f against %s", ppm, ref)
+		if math.Abs(residual) < 1.0 {
+			return ppm, nil
+		}
+		applied = math.Round(ppm)
+		if err := s.SetFreqCorrection(uint32(int32(applied))); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.SetFreqCorrection(uint32(orig)); err != nil {
+		return 0, err
 	}
-	return 1e6 * df / targetFreq, nil
+	return 0, ErrNoCalibration
 }
@@ -0,0 +1,66 @@
+package radio
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestCalibrateSim(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	scene := SimScene{
+		NoiseDB: -50,
+		PPM:     23,
+		Signals: []SimSignal{
+			{Type: SimCarrier, Hz: 144390000, DB: -20},
+			{Type: SimFM, Hz: 100100000, DB: -15, ToneHz: 440, DeviationHz: 75000, Stereo: true},
+		},
+	}
+	sdr := NewSimSDR(ctx, "sim:cal", scene)
+	defer sdr.Close()
+
+	tests := []struct {
+		ref CalReference
+		tol float64
+	}{
+		{CalReference{Type: CalBeacon, Hz: 144390000}, 1},
+		{CalReference{Type: CalFMPilot, Hz: 100100000}, 2},
+	}
+	for _, tt := range tests {
+		ppm, err := MeasurePPM(sdr, tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(ppm-23) > tt.tol {
+			t.Errorf("%s: measured %.2fppm, expected 23", tt.ref, ppm)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "cal.json")
+	tbl, err := LoadCalibrationTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := tbl.Calibrate(sdr, tests[0].ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(c.PPM-23) > 1 || sdr.Info().PPM != 23 {
+		t.Fatalf("calibrated to %.2fppm, applied %d", c.PPM, sdr.Info().PPM)
+	}
+
+	// A fresh process applies the saved correction on open.
+	if tbl, err = LoadCalibrationTable(path); err != nil {
+		t.Fatal(err)
+	}
+	sdr2 := NewSimSDR(ctx, "sim:cal", scene)
+	defer sdr2.Close()
+	if err := tbl.Apply(sdr2); err != nil {
+		t.Fatal(err)
+	}
+	if ppm := sdr2.Info().PPM; ppm != 23 {
+		t.Fatalf("applied %dppm, expected 23", ppm)
+	}
+}
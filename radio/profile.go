@@ -0,0 +1,208 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DirectSampling selects how an RTL-SDR receives HF.
+type DirectSampling string
+
+const (
+	// DirectSamplingAuto samples the Q branch below 25MHz.
+	DirectSamplingAuto DirectSampling = ""
+	// DirectSamplingOff always uses the tuner.
+	DirectSamplingOff DirectSampling = "off"
+	// DirectSamplingI samples the I branch below 25MHz.
+	DirectSamplingI DirectSampling = "i"
+	// DirectSamplingQ samples the Q branch below 25MHz.
+	DirectSamplingQ DirectSampling = "q"
+)
+
+func ParseDirectSampling(s string) (DirectSampling, error) {
+	switch ds := DirectSampling(strings.ToLower(s)); ds {
+	case DirectSamplingAuto, DirectSamplingOff, DirectSamplingI, DirectSamplingQ:
+		return ds, nil
+	}
+	return "", fmt.Errorf("unknown direct sampling mode %q", s)
+}
+
+// FreqRange is a span of frequencies, inclusive.
+type FreqRange struct {
+	MinHz uint64 `json:"min_hz"`
+	MaxHz uint64 `json:"max_hz"`
+}
+
+func (fr FreqRange) Contains(b HzBand) bool {
+	// Written to not underflow for bands reaching below zero.
+	return b.Center >= fr.MinHz+b.Width/2 && b.Center+b.Width/2 <= fr.MaxHz
+}
+
+// DeviceProfile is the configuration of a radio on the bench.
+type DeviceProfile struct {
+	// Name is a friendly name radios may be opened by instead of serial.
+	Name string `json:"name,omitempty"`
+	// PPM pins the frequency correction instead of using calibrations.
+	PPM *int32 `json:"ppm,omitempty"`
+	// Gain is applied when the radio opens.
+	Gain *GainConfig `json:"gain,omitempty"`
+	// BiasTee keeps the antenna powered regardless of gain settings.
+	BiasTee bool `json:"bias_tee,omitempty"`
+	// Ranges limit tuning to what the antenna and filters cover.
+	Ranges []FreqRange `json:"ranges,omitempty"`
+	// SampleRate is the preferred rate when the tuning is up to the server.
+	SampleRate uint32 `json:"sample_rate,omitempty"`
+
+	// DirectSampling and UpconverterHz describe an HF front end.
+	DirectSampling DirectSampling `json:"direct_sampling,omitempty"`
+	// UpconverterHz is the LO of an upconverter in front of the radio.
+	// Bands are given at the antenna and shifted up by the LO.
+	UpconverterHz uint64 `json:"upconverter_hz,omitempty"`
+}
+
+var errProfileHF = errors.New("profile has both direct sampling and an upconverter")
+
+// ParseDeviceProfile accepts "direct:i", "direct:q", "direct:off", or
+// "upconverter:<lo hz>".
+func ParseDeviceProfile(s string) (p DeviceProfile, err error) {
+	k, v, _ := strings.Cut(s, ":")
+	switch strings.ToLower(k) {
+	case "direct":
+		p.DirectSampling, err = ParseDirectSampling(v)
+	case "upconverter":
+		p.UpconverterHz, err = strconv.ParseUint(v, 10, 64)
+	default:
+		err = fmt.Errorf("unknown device profile %q", s)
+	}
+	return p, err
+}
+
+func (p DeviceProfile) IsZero() bool {
+	return p.Name == "" && p.PPM == nil && p.Gain == nil && !p.BiasTee &&
+		len(p.Ranges) == 0 && p.SampleRate == 0 &&
+		p.DirectSampling == DirectSamplingAuto && p.UpconverterHz == 0
+}
+
+// Covers is true if the profile allows tuning to the band.
+func (p DeviceProfile) Covers(b HzBand) bool {
+	if len(p.Ranges) == 0 {
+		return true
+	}
+	for _, fr := range p.Ranges {
+		if fr.Contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// directSampler is an SDR that can feed the antenna straight to its ADC.
+type directSampler interface {
+	setDirectSampling(DirectSampling) error
+}
+
+// ppmPinner is an SDR that would otherwise calibrate itself.
+type ppmPinner interface {
+	pinFreqCorrection(ppm uint32) error
+}
+
+// profileSDR translates bands between the antenna and the radio.
+type profileSDR struct {
+	SDR
+	p DeviceProfile
+
+	// iqr wraps inner, the radio's reader, with the antenna band.
+	iqr, inner *MixerIQReader
+	mu         sync.Mutex
+}
+
+// WithProfile configures the SDR's front end, correction, and gain. Bands
+// passed to and reported by the returned SDR are at the antenna.
+func WithProfile(sdr SDR, p DeviceProfile) (SDR, error) {
+	if p.IsZero() {
+		return sdr, nil
+	}
+	mode := p.DirectSampling
+	if p.UpconverterHz != 0 {
+		if mode != DirectSamplingAuto && mode != DirectSamplingOff {
+			return nil, errProfileHF
+		}
+		mode = DirectSamplingOff
+	}
+	if ds, ok := sdr.(directSampler); ok {
+		if err := ds.setDirectSampling(mode); err != nil {
+			return nil, err
+		}
+	} else if p.DirectSampling != DirectSamplingAuto {
+		return nil, ErrUnsupported
+	}
+	if p.PPM != nil {
+		set := sdr.SetFreqCorrection
+		if pp, ok := sdr.(ppmPinner); ok {
+			set = pp.pinFreqCorrection
+		}
+		if err := set(uint32(*p.PPM)); err != nil {
+			return nil, err
+		}
+	}
+	ps := &profileSDR{SDR: sdr, p: p}
+	if p.Gain != nil || p.BiasTee {
+		g := sdr.Info().Gain
+		if p.Gain != nil {
+			g = *p.Gain
+		}
+		if err := ps.SetGain(g); err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+func (s *profileSDR) SetBand(b HzBand) error {
+	if !s.p.Covers(b) {
+		return ErrFrequencyOutOfRange
+	}
+	b.Center += s.p.UpconverterHz
+	return s.SDR.SetBand(b)
+}
+
+func (s *profileSDR) SetGain(g GainConfig) error {
+	g.BiasTee = g.BiasTee || s.p.BiasTee
+	return s.SDR.SetGain(g)
+}
+
+func (s *profileSDR) Info() SDRHWInfo { return s.p.ApplyInfo(s.SDR.Info()) }
+
+// ApplyInfo reports the radio's frequencies at the antenna, limited to the
+// profile's ranges.
+func (p DeviceProfile) ApplyInfo(info SDRHWInfo) SDRHWInfo {
+	if p.IsZero() {
+		return info
+	}
+	lo := p.UpconverterHz
+	info.CenterHz = max(info.CenterHz, lo) - lo
+	info.MinHz, info.MaxHz = max(info.MinHz, lo)-lo, max(info.MaxHz, lo)-lo
+	if len(p.Ranges) > 0 {
+		rlo, rhi := p.Ranges[0].MinHz, p.Ranges[0].MaxHz
+		for _, fr := range p.Ranges[1:] {
+			rlo, rhi = min(rlo, fr.MinHz), max(rhi, fr.MaxHz)
+		}
+		info.MinHz, info.MaxHz = max(info.MinHz, rlo), min(info.MaxHz, rhi)
+	}
+	info.Profile = &p
+	return info
+}
+
+func (s *profileSDR) Reader() *MixerIQReader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r := s.SDR.Reader(); r != s.inner {
+		b := r.HzBand
+		b.Center = max(b.Center, s.p.UpconverterHz) - s.p.UpconverterHz
+		s.iqr, s.inner = &MixerIQReader{HzBand: b, IQReader: r.IQReader}, r
+	}
+	return s.iqr
+}
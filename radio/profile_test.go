@@ -0,0 +1,168 @@
+package radio
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileUpconverter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	// A 7.15MHz carrier behind a 125MHz upconverter.
+	const lo = 125000000
+	scene := SimScene{
+		NoiseDB: -50,
+		Signals: []SimSignal{{Type: SimCarrier, Hz: lo + 7150000, DB: -20}},
+	}
+	rsdr := NewSimSDR(ctx, "sim:hf", scene)
+	defer rsdr.Close()
+	if _, err := WithProfile(rsdr, DeviceProfile{DirectSampling: DirectSamplingQ}); err != ErrUnsupported {
+		t.Fatalf("expected direct sampling unsupported, got %v", err)
+	}
+	sdr, err := WithProfile(rsdr, DeviceProfile{UpconverterHz: lo})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hf := HzBand{Center: 7100000, Width: 240000}
+	if err := sdr.SetBand(hf); err != nil {
+		t.Fatal(err)
+	}
+	if info := sdr.Info(); info.HzBand() != hf || info.Profile == nil || info.Profile.UpconverterHz != lo {
+		t.Fatalf("expected %+v with profile, got %+v", hf, info)
+	}
+	if got := rsdr.Info(); got.CenterHz != lo+hf.Center {
+		t.Fatalf("radio tuned to %d, expected %d", got.CenterHz, lo+hf.Center)
+	}
+	if b := sdr.Reader().HzBand; b != hf {
+		t.Fatalf("reader has band %+v, expected %+v", b, hf)
+	}
+
+	// The carrier is found at its HF frequency.
+	ppm, err := MeasurePPM(sdr, CalReference{Type: CalBeacon, Hz: 7150000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(ppm) > 20 {
+		t.Fatalf("carrier off by %.2fppm", ppm)
+	}
+}
+
+func TestProfileDirectSampling(t *testing.T) {
+	tests := []struct {
+		mode DirectSampling
+		hz   uint32
+		ds   uint32
+	}{
+		{DirectSamplingAuto, 7100000, 2},
+		{DirectSamplingAuto, 100000000, 0},
+		{DirectSamplingI, 7100000, 1},
+		{DirectSamplingQ, 7100000, 2},
+		{DirectSamplingOff, 7100000, 0},
+	}
+	for _, tt := range tests {
+		s := &rtlSDR{}
+		if err := s.setDirectSampling(tt.mode); err != nil {
+			t.Fatal(err)
+		}
+		if ds := s.directSamplingAt(tt.hz); ds != tt.ds {
+			t.Errorf("%q at %d: got %d, expected %d", tt.mode, tt.hz, ds, tt.ds)
+		}
+	}
+}
+
+func TestProfileTable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	js := `{"sim:": {
+		"name": "bench-vhf",
+		"ppm": 12,
+		"gain": {"gain_tenth_db": 297},
+		"bias_tee": true,
+		"ranges": [{"min_hz": 88000000, "max_hz": 108000000}, {"min_hz": 144000000, "max_hz": 148000000}],
+		"sample_rate": 1024000
+	}}`
+	if err := os.WriteFile(path, []byte(js), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := LoadProfileTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ser := tbl.Resolve("bench-vhf"); ser != "sim:" {
+		t.Fatalf("resolved name to %q", ser)
+	}
+
+	sdr, err := tbl.Open(ctx, "bench-vhf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	info := sdr.Info()
+	if info.PPM != 12 || info.Gain.TenthDB != 297 || !info.Gain.BiasTee {
+		t.Fatalf("profile not applied: %+v", info)
+	}
+	if info.Profile == nil || info.Profile.Name != "bench-vhf" {
+		t.Fatalf("expected profile in info, got %+v", info.Profile)
+	}
+	if info.MinHz != 88000000 || info.MaxHz != 148000000 {
+		t.Fatalf("expected coverage 88-148MHz, got %d-%d", info.MinHz, info.MaxHz)
+	}
+	if err := sdr.SetBand(HzBand{Center: 100100000, Width: 240000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sdr.SetBand(HzBand{Center: 433920000, Width: 240000}); err != ErrFrequencyOutOfRange {
+		t.Fatalf("expected out of range, got %v", err)
+	}
+	if b := (HzBand{Center: 500000, Width: 2048000}); tbl.Get("sim:").Covers(b) {
+		t.Fatalf("expected %+v out of range", b)
+	}
+	// The antenna stays powered.
+	if err := sdr.SetGain(GainConfig{TenthDB: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if !sdr.Info().Gain.BiasTee {
+		t.Fatal("bias tee turned off")
+	}
+
+	infos := []SDRHWInfo{{Id: "sim:", MaxHz: 1750000000}, {Id: "other"}}
+	tbl.ApplyInfo(infos)
+	if infos[0].Profile == nil || infos[1].Profile != nil {
+		t.Fatalf("bad listing %+v", infos)
+	}
+}
+
+// pinSDR records whether its correction was pinned.
+type pinSDR struct {
+	SDR
+	pinned bool
+}
+
+func (s *pinSDR) pinFreqCorrection(ppm uint32) error {
+	s.pinned = true
+	return s.SetFreqCorrection(ppm)
+}
+
+func TestProfilePinnedPPM(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	ppm := int32(7)
+	for _, p := range []DeviceProfile{{}, {PPM: &ppm}} {
+		ps := &pinSDR{SDR: NewSimSDR(ctx, "sim:pin", SimScene{})}
+		sdr, err := WithProfile(ps, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps.pinned != (p.PPM != nil) {
+			t.Fatalf("profile %+v: pinned=%v", p, ps.pinned)
+		}
+		if p.PPM != nil && sdr.Info().PPM != ppm {
+			t.Fatalf("expected %dppm, got %d", ppm, sdr.Info().PPM)
+		}
+		sdr.Close()
+	}
+}
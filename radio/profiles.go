@@ -0,0 +1,96 @@
+package radio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ProfileTable holds device profiles keyed by serial.
+type ProfileTable struct {
+	devs map[string]DeviceProfile
+	mu   sync.RWMutex
+}
+
+// DefaultProfilePath is profiles.json in the user's nicerx config directory.
+func DefaultProfilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "profiles.json"
+	}
+	return filepath.Join(dir, "nicerx", "profiles.json")
+}
+
+func NewProfileTable() *ProfileTable {
+	return &ProfileTable{devs: make(map[string]DeviceProfile)}
+}
+
+// LoadProfileTable reads the table at path; a missing file is empty.
+func LoadProfileTable(path string) (*ProfileTable, error) {
+	t := NewProfileTable()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &t.devs); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *ProfileTable) Get(serial string) DeviceProfile {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.devs[serial]
+}
+
+// Set replaces a profile until the table is reloaded.
+func (t *ProfileTable) Set(serial string, p DeviceProfile) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.devs[serial] = p
+}
+
+// Resolve maps a friendly name to its serial; anything else is returned
+// as is.
+func (t *ProfileTable) Resolve(id string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if _, ok := t.devs[id]; ok {
+		return id
+	}
+	for ser, p := range t.devs {
+		if p.Name == id {
+			return ser
+		}
+	}
+	return id
+}
+
+// Open opens a radio by serial or name and applies its profile.
+func (t *ProfileTable) Open(ctx context.Context, id string) (SDR, error) {
+	ser := t.Resolve(id)
+	sdr, err := NewSDRWithSerial(ctx, ser)
+	if err != nil {
+		return nil, err
+	}
+	psdr, err := WithProfile(sdr, t.Get(ser))
+	if err != nil {
+		sdr.Close()
+		return nil, err
+	}
+	return psdr, nil
+}
+
+// ApplyInfo applies each radio's profile to a radio listing.
+func (t *ProfileTable) ApplyInfo(infos []SDRHWInfo) {
+	for i := range infos {
+		infos[i] = t.Get(infos[i].Id).ApplyInfo(infos[i])
+	}
+}
@@ -0,0 +1,382 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chzchzchz/nicerx/radio/sigmf"
+	"github.com/chzchzchz/nicerx/radio/wav"
+)
+
+const filePrefix = "file:"
+
+// How often the replay emits a block of samples.
+const fileTick = 20 * time.Millisecond
+
+// FileSDRConfig describes a recording to replay as an SDR.
+type FileSDRConfig struct {
+	Path string
+	// Band is the recorded center frequency and sample rate. Filled in from
+	// SigMF metadata, the file name, or wav header if zero.
+	Band HzBand
+	// Format is the sample encoding. Filled in from SigMF metadata or the
+	// file extension if empty.
+	Format SampleFormat
+	// Speed is a multiple of the recorded sample rate; defaults to 1.
+	Speed float64
+	// Loop restarts the recording on end of file.
+	Loop bool
+}
+
+type fileSDR struct {
+	ser string
+	cfg FileSDRConfig
+
+	iqr    *MixerIQReader
+	cancel context.CancelFunc
+	donec  <-chan struct{}
+
+	ctx context.Context
+	mu  sync.Mutex
+}
+
+// NewFileSDR replays an I/Q, SigMF, or wav recording at its recorded rate.
+func NewFileSDR(ctx context.Context, ser string, cfg FileSDRConfig) (SDR, error) {
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+	if sigmf.IsSigMF(cfg.Path) {
+		b, f, err := ReadSigMF(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Band.Center == 0 {
+			cfg.Band.Center = b.Center
+		}
+		if cfg.Band.Width == 0 {
+			cfg.Band.Width = b.Width
+		}
+		if cfg.Format == "" {
+			cfg.Format = f
+		}
+		cfg.Path = sigmf.DataPath(cfg.Path)
+	}
+	if cfg.Format == "" {
+		cfg.Format = PathSampleFormat(cfg.Path)
+	}
+	if cfg.Band.Center == 0 || cfg.Band.Width == 0 {
+		if b, ok := RecordingBand(cfg.Path); ok {
+			if cfg.Band.Center == 0 {
+				cfg.Band.Center = b.Center
+			}
+			if cfg.Band.Width == 0 {
+				cfg.Band.Width = b.Width
+			}
+		}
+	}
+	// The wav header has the true rate and format.
+	if strings.HasSuffix(cfg.Path, ".wav") {
+		r, closer, err := openRecording(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		b, f, err := WavTuning(r.(*wav.Reader))
+		closer()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Band.Center == 0 {
+			cfg.Band.Center = b.Center
+		}
+		cfg.Band.Width, cfg.Format = b.Width, f
+	}
+	if cfg.Band.Width == 0 {
+		return nil, fmt.Errorf("unknown sample rate for %q", cfg.Path)
+	}
+	if _, err := os.Stat(cfg.Path); err != nil {
+		return nil, err
+	}
+	return &fileSDR{ser: ser, cfg: cfg, ctx: ctx}, nil
+}
+
+// newFileSDRWithSerial parses serials of the form
+// "file:path?center=hz&rate=hz&speed=x&loop=1".
+func newFileSDRWithSerial(ctx context.Context, ser string) (SDR, error) {
+	p, q := strings.TrimPrefix(ser, filePrefix), ""
+	if i := strings.LastIndex(p, "?"); i >= 0 {
+		p, q = p[:i], p[i+1:]
+	}
+	vals, err := url.ParseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	cfg := FileSDRConfig{Path: p}
+	if v := vals.Get("center"); v != "" {
+		if cfg.Band.Center, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if v := vals.Get("rate"); v != "" {
+		if cfg.Band.Width, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if v := vals.Get("speed"); v != "" {
+		if cfg.Speed, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+	}
+	if v := vals.Get("loop"); v != "" {
+		if cfg.Loop, err = strconv.ParseBool(v); err != nil {
+			return nil, err
+		}
+	}
+	return NewFileSDR(ctx, ser, cfg)
+}
+
+var (
+	// iqscope recordings: "center[width].iq8"
+	scopeNameRE = regexp.MustCompile(`(\d+)\[(\d+)\]`)
+	// sdrproxy streams: "center:[lo,hi].iq8"
+	proxyNameRE = regexp.MustCompile(`(\d+):\[(\d+),(\d+)\]`)
+	// SignalStore captures: "centerMHz/nanos.width.iq" or ".sigmf-data"
+	storeNameRE = regexp.MustCompile(`^\d+\.(\d+)\.(iq|sigmf-data)`)
+)
+
+// RecordingBand guesses the tuning of a recording from its file name.
+func RecordingBand(path string) (HzBand, bool) {
+	base := filepath.Base(path)
+	if m := proxyNameRE.FindStringSubmatch(base); m != nil {
+		c, _ := strconv.ParseUint(m[1], 10, 64)
+		lo, _ := strconv.ParseUint(m[2], 10, 64)
+		hi, _ := strconv.ParseUint(m[3], 10, 64)
+		return HzBand{Center: c, Width: hi - lo}, true
+	}
+	if m := scopeNameRE.FindStringSubmatch(base); m != nil {
+		c, _ := strconv.ParseUint(m[1], 10, 64)
+		w, _ := strconv.ParseUint(m[2], 10, 64)
+		return HzBand{Center: c, Width: w}, true
+	}
+	if m := storeNameRE.FindStringSubmatch(base); m != nil {
+		mhz, err := strconv.ParseFloat(filepath.Base(filepath.Dir(path)), 64)
+		if err != nil {
+			return HzBand{}, false
+		}
+		w, _ := strconv.ParseUint(m[1], 10, 64)
+		return HzBand{Center: uint64(mhz * 1e6), Width: w}, true
+	}
+	return HzBand{}, false
+}
+
+// NewSigMFMeta describes a recording of band b in format f starting at t,
+// received by hw if known.
+func NewSigMFMeta(f SampleFormat, b HzBand, t time.Time, hw *SDRHWInfo) *sigmf.Meta {
+	m := &sigmf.Meta{
+		Global: sigmf.Global{
+			Datatype:   f.SigMFDatatype(),
+			SampleRate: float64(b.Width),
+			Version:    sigmf.Version,
+			Recorder:   "nicerx",
+		},
+		Captures: []sigmf.Capture{sigmf.NewCapture(0, float64(b.Center), t)},
+	}
+	if hw != nil {
+		m.Global.Extensions = []sigmf.Extension{sigmf.NicerxExtension}
+		m.Global.HW = hw.Id
+		m.Global.Serial = hw.Id
+		m.Global.PPM = float64(hw.PPM)
+	}
+	return m
+}
+
+// ReadSigMF reads the band and format of a SigMF recording.
+func ReadSigMF(path string) (HzBand, SampleFormat, error) {
+	m, err := sigmf.ReadFile(path)
+	if err != nil {
+		return HzBand{}, "", err
+	}
+	f, err := ParseSampleFormat(m.Global.Datatype)
+	if err != nil {
+		return HzBand{}, "", err
+	}
+	return HzBand{Center: uint64(m.Frequency()), Width: uint64(m.Global.SampleRate)}, f, nil
+}
+
+// WavTuning reads the band and format of a stereo I/Q wav. The center
+// frequency is only known if the wav has an auxi chunk.
+func WavTuning(r *wav.Reader) (HzBand, SampleFormat, error) {
+	if r.Channels() != 2 {
+		return HzBand{}, "", fmt.Errorf("expected 2 channel I/Q wav, got %d", r.Channels())
+	}
+	var f SampleFormat
+	switch {
+	case r.Format() == wav.FormatFloat:
+		f = FormatCF32LE
+	case r.BitDepth() == 16:
+		f = FormatCS16LE
+	default:
+		f = FormatCU8
+	}
+	b := HzBand{Width: uint64(r.SampleRate())}
+	if aux := r.Auxi(); aux != nil {
+		b.Center = uint64(aux.CenterHz)
+	}
+	return b, f, nil
+}
+
+// NewWavIQWriter writes a stereo I/Q wav with an auxi chunk for the band.
+// Write samples to it with an IQWriter of the same format.
+func NewWavIQWriter(w io.Writer, f SampleFormat, b HzBand, t time.Time) (*wav.Writer, error) {
+	aux := &wav.Auxi{StartTime: t, CenterHz: uint32(b.Center), BandwidthHz: uint32(b.Width)}
+	switch f {
+	case FormatCU8:
+		return wav.NewWriterFormat(w, int(b.Width), 8, 2, wav.FormatPCM, aux)
+	case FormatCS16LE:
+		return wav.NewWriterFormat(w, int(b.Width), 16, 2, wav.FormatPCM, aux)
+	case FormatCF32LE:
+		return wav.NewWriterFormat(w, int(b.Width), 32, 2, wav.FormatFloat, aux)
+	}
+	return nil, fmt.Errorf("%s not supported by wav", f)
+}
+
+func openRecording(path string) (io.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasSuffix(path, ".wav") {
+		r, err := wav.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return r, func() { f.Close() }, nil
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// SetBand only accepts the recorded band; channels are mixed down from it.
+func (f *fileSDR) SetBand(b HzBand) error {
+	if b.Width != f.cfg.Band.Width {
+		return ErrRateOutOfRange
+	}
+	if b.Center != f.cfg.Band.Center {
+		return ErrFrequencyOutOfRange
+	}
+	return nil
+}
+
+func (f *fileSDR) span() (lo, hi int64) {
+	rec := f.cfg.Band
+	return int64(rec.Center) - int64(rec.Width/2), int64(rec.Center + rec.Width/2)
+}
+
+func (f *fileSDR) SetFreqCorrection(ppm uint32) error {
+	if ppm != 0 {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+func (f *fileSDR) SetGain(g GainConfig) error { return ErrUnsupported }
+
+func (f *fileSDR) Info() SDRHWInfo {
+	rec := f.cfg.Band
+	lo, hi := f.span()
+	if lo < 0 {
+		lo = 0
+	}
+	return SDRHWInfo{
+		Id: f.ser,
+		SDRFormat: SDRFormat{
+			BitDepth:   f.cfg.Format.BitDepth(),
+			Format:     f.cfg.Format,
+			CenterHz:   rec.Center,
+			SampleRate: uint32(rec.Width),
+		},
+		MinHz:         uint64(lo),
+		MaxHz:         uint64(hi),
+		MinSampleRate: uint32(rec.Width),
+		MaxSampleRate: uint32(rec.Width),
+	}
+}
+
+func (f *fileSDR) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cancel != nil {
+		f.cancel()
+		<-f.donec
+		f.cancel, f.iqr = nil, nil
+	}
+	return nil
+}
+
+func (f *fileSDR) Reader() *MixerIQReader {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.iqr == nil {
+		pr, pw := io.Pipe()
+		cctx, cancel := context.WithCancel(f.ctx)
+		donec := make(chan struct{})
+		f.cancel, f.donec = cancel, donec
+		go func() {
+			defer close(donec)
+			pw.CloseWithError(f.run(cctx, pw))
+		}()
+		go func() {
+			<-cctx.Done()
+			pr.Close()
+		}()
+		f.iqr = NewIQReaderFormat(pr, f.cfg.Format).ToMixer(f.cfg.Band)
+	}
+	return f.iqr
+}
+
+func (f *fileSDR) run(ctx context.Context, w io.Writer) error {
+	sampBytes := f.cfg.Format.SampleBytes()
+	chunk := int(float64(f.cfg.Band.Width)*f.cfg.Speed*fileTick.Seconds()) * sampBytes
+	buf := make([]byte, chunk)
+	ticker := time.NewTicker(fileTick)
+	defer ticker.Stop()
+	for {
+		r, closer, err := openRecording(f.cfg.Path)
+		if err != nil {
+			return err
+		}
+		err = replay(ctx, ticker, r, w, buf, sampBytes)
+		closer()
+		if err != io.EOF || !f.cfg.Loop {
+			return err
+		}
+	}
+}
+
+func replay(ctx context.Context, ticker *time.Ticker, r io.Reader, w io.Writer, buf []byte, sampBytes int) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		n, err := io.ReadFull(r, buf)
+		// Only send whole I/Q pairs.
+		if _, werr := w.Write(buf[:n-n%sampBytes]); werr != nil {
+			return werr
+		}
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		} else if err != nil {
+			return err
+		}
+	}
+}
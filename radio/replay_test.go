@@ -0,0 +1,97 @@
+package radio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	band := HzBand{Center: 100000000, Width: 50000}
+	path := filepath.Join(t.TempDir(), "100000000[50000].iq8")
+	samps := make([]complex64, band.Width/10)
+	for i := range samps {
+		samps[i] = complex(float32(i%100)/100, -0.5)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewIQWriter(f).Write64(samps); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	sdr, err := NewSDRWithSerial(ctx, "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	if info := sdr.Info(); info.HzBand() != band || info.Format != FormatCU8 {
+		t.Fatalf("got %+v, expected %+v as %s", info.SDRFormat, band, FormatCU8)
+	}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	if err := sdr.SetBand(HzBand{Center: 100010000, Width: band.Width}); err != ErrFrequencyOutOfRange {
+		t.Fatalf("expected out of range frequency, got %v", err)
+	}
+	if err := sdr.SetBand(HzBand{Center: band.Center, Width: 2 * band.Width}); err != ErrRateOutOfRange {
+		t.Fatalf("expected out of range rate, got %v", err)
+	}
+	got := <-sdr.Reader().BatchStream64(ctx, len(samps), 1)
+	if len(got) != len(samps) {
+		t.Fatalf("got %d samples, expected %d", len(got), len(samps))
+	}
+	for i := range got {
+		if d := got[i] - samps[i]; real(d)*real(d)+imag(d)*imag(d) > 1e-4 {
+			t.Fatalf("sample %d: got %v, expected %v", i, got[i], samps[i])
+		}
+	}
+}
+
+func TestReplaySigMF(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	band := HzBand{Center: 100000000, Width: 50000}
+	path := filepath.Join(t.TempDir(), "test.sigmf-data")
+	samps := make([]complex64, band.Width/10)
+	for i := range samps {
+		samps[i] = complex(float32(i%100)/100, -0.5)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewIQWriterFormat(f, FormatCS16LE).Write64(samps); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := NewSigMFMeta(FormatCS16LE, band, time.Now(), nil).WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	sdr, err := NewSDRWithSerial(ctx, "file:"+filepath.Join(filepath.Dir(path), "test.sigmf-meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	if info := sdr.Info(); info.HzBand() != band || info.Format != FormatCS16LE {
+		t.Fatalf("got %+v, expected %+v as %s", info.SDRFormat, band, FormatCS16LE)
+	}
+	got := <-sdr.Reader().BatchStream64(ctx, len(samps), 1)
+	if len(got) != len(samps) {
+		t.Fatalf("got %d samples, expected %d", len(got), len(samps))
+	}
+	for i := range got {
+		if d := got[i] - samps[i]; real(d)*real(d)+imag(d)*imag(d) > 1e-8 {
+			t.Fatalf("sample %d: got %v, expected %v", i, got[i], samps[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package radio
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"time"
+)
+
+const tcpPrefix = "tcp://"
+
+const defaultRTLTCPPort = "1234"
+
+// How long to keep redialing a dropped rtl_tcp connection.
+const redialTimeout = time.Minute
+
+// newRemoteRTLSDR connects to an rtl_tcp server that is already running
+// elsewhere, given a serial of the form "tcp://host:port".
+func newRemoteRTLSDR(ctx context.Context, ser string) (*rtlSDR, error) {
+	u, err := url.Parse(ser)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultRTLTCPPort)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	s := &rtlSDR{ctx: cctx, cancel: cancel, serialNumber: ser, gain: DefaultGain, addr: addr}
+	// Fail early if nothing usable is listening.
+	if err := s.initSDR(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// rtlConnReader reads samples from an rtl_tcp connection, redialing and
+// restoring the tuning if the connection drops.
+type rtlConnReader struct {
+	s   *rtlSDR
+	sdr *RTLTCPSDR
+}
+
+func (r *rtlConnReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.sdr.Read(p)
+		if err == nil || n > 0 {
+			return n, nil
+		}
+		log.Printf("rtl_tcp %s dropped: %v", r.s.addr, err)
+		if r.sdr, err = r.s.redial(r.sdr); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// redial replaces a dropped connection unless it was closed on purpose.
+// The lock isn't held while dialing so the SDR can be retuned or closed.
+func (s *rtlSDR) redial(old *RTLTCPSDR) (*RTLTCPSDR, error) {
+	if !s.current(old) {
+		// Retuned or closed; the old reader is done.
+		return nil, io.EOF
+	}
+	old.Close()
+	ctx, cancel := context.WithTimeout(s.ctx, redialTimeout)
+	defer cancel()
+	for {
+		sdr, err := connect(ctx, s.addr)
+		if err == nil {
+			if err = s.restore(sdr); err == nil {
+				if !s.replace(old, sdr) {
+					sdr.Close()
+					return nil, io.EOF
+				}
+				log.Printf("rtl_tcp %s reconnected", s.addr)
+				return sdr, nil
+			}
+			sdr.Close()
+		}
+		select {
+		case <-ctx.Done():
+			if s.gone.Load() {
+				return nil, ErrDeviceGone
+			}
+			return nil, err
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *rtlSDR) current(sdr *RTLTCPSDR) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sdr == sdr
+}
+
+// replace installs a redialed connection if old is still current.
+func (s *rtlSDR) replace(old, sdr *RTLTCPSDR) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sdr != old {
+		return false
+	}
+	s.sdr = sdr
+	return true
+}
+
+// restore applies the last known settings to a fresh connection.
+func (s *rtlSDR) restore(sdr *RTLTCPSDR) error {
+	if s.lastPPM != 0 {
+		if err := sdr.SetFreqCorrection(s.lastPPM); err != nil {
+			return err
+		}
+	}
+	if s.lastSampleRate != 0 {
+		if err := sdr.SetSampleRate(s.lastSampleRate); err != nil {
+			return err
+		}
+	}
+	if s.lastCenter == 0 {
+		return nil
+	}
+	if err := sdr.SetDirectSampling(s.directSamplingAt(s.lastCenter)); err != nil {
+		return err
+	}
+	if err := applyGain(sdr, s.gain); err != nil {
+		return err
+	}
+	return sdr.SetCenterFreq(s.lastCenter)
+}
@@ -3,6 +3,7 @@ package radio
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kr/pty"
@@ -25,11 +27,16 @@ const directSampMaxHz = 25000000
 
 type rtlSDR struct {
 	sdr    *RTLTCPSDR
-	cmd    *exec.Cmd
-	fpty   *os.File
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// proc is the supervised rtl_tcp child, if not remote.
+	proc  *rtlTCPProc
+	port  int
+	exitc chan struct{}
+	// gone is set once the device can't be restarted.
+	gone atomic.Bool
+
 	// device serial number or device index
 	serialNumber string
 
@@ -37,6 +44,13 @@ type rtlSDR struct {
 	lastSampleRate    uint32
 	lastPPM           uint32
 	lastCalibrateTime time.Time
+	gain              GainConfig
+	// pinnedPPM is set when a profile configures the correction,
+	// disabling automatic calibration.
+	pinnedPPM bool
+	// directSampling is the HF mode; dsStale is set when it changes.
+	directSampling DirectSampling
+	dsStale        bool
 
 	iqr *MixerIQReader
 	mu  sync.RWMutex
@@ -44,20 +58,28 @@ type rtlSDR struct {
 	addr string
 }
 
-// TODO: port pool
-const portBase = 12345
+// How many times to restart a crashed rtl_tcp before giving up on the
+// device.
+const rtlRestarts = 5
 
-var port = 0
+// rtlTCPProc is a running rtl_tcp child.
+type rtlTCPProc struct {
+	cmd    *exec.Cmd
+	fpty   *os.File
+	cancel context.CancelFunc
+}
 
-func newRTLSDR(ctx context.Context, ser string) (*rtlSDR, error) {
-	port++
-	p := fmt.Sprint((port % 64) + portBase)
+// startRTLTCP runs rtl_tcp for the device on a local port and waits for it
+// to listen.
+func startRTLTCP(ctx context.Context, ser string, port int) (*rtlTCPProc, error) {
 	cctx, cancel := context.WithCancel(ctx)
-	cmd := exec.CommandContext(cctx, "rtl_tcp", "-a", "127.0.0.1", "-p", p, "-d", ser, "-s", "240000")
+	cmd := exec.CommandContext(cctx, "rtl_tcp", "-a", "127.0.0.1", "-p", fmt.Sprint(port), "-d", ser, "-s", "240000")
 	fpty, err := pty.Start(cmd)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	proc := &rtlTCPProc{cmd: cmd, fpty: fpty, cancel: cancel}
 
 	// Wait for sdr to set up.
 	readyc := make(chan error, 1)
@@ -73,34 +95,89 @@ func newRTLSDR(ctx context.Context, ser string) (*rtlSDR, error) {
 			if strings.Contains(l, "listening...") {
 				return
 			}
+			if strings.Contains(l, "No supported devices") || strings.Contains(l, "No matching devices") {
+				readyc <- ErrDeviceGone
+				return
+			}
 		}
 	}()
 
 	select {
 	case <-time.After(2 * time.Second):
 		// Should take <1s to set up.
-		cancel()
-		<-readyc
-		return nil, io.EOF
+		err = io.EOF
 	case <-cctx.Done():
+		err = cctx.Err()
+	case err = <-readyc:
+	}
+	if err != nil {
 		cancel()
 		<-readyc
-		return nil, cctx.Err()
-	case err := <-readyc:
-		if err != nil {
-			return nil, err
-		}
+		proc.wait()
+		return nil, err
 	}
 
 	go io.Copy(os.Stdout, fpty)
+	return proc, nil
+}
+
+func (p *rtlTCPProc) wait() error {
+	err := p.cmd.Wait()
+	p.cancel()
+	p.fpty.Close()
+	return err
+}
 
-	return &rtlSDR{
-		fpty:         fpty,
+func newRTLSDR(ctx context.Context, ser string) (*rtlSDR, error) {
+	port, err := rtlPorts.get()
+	if err != nil {
+		return nil, err
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	proc, err := startRTLTCP(cctx, ser, port)
+	if err != nil {
+		cancel()
+		rtlPorts.put(port)
+		return nil, err
+	}
+	s := &rtlSDR{
+		proc:         proc,
+		port:         port,
+		exitc:        make(chan struct{}),
 		ctx:          cctx,
 		cancel:       cancel,
-		cmd:          cmd,
 		serialNumber: ser,
-		addr:         "127.0.0.1:" + p}, nil
+		gain:         DefaultGain,
+		addr:         fmt.Sprintf("127.0.0.1:%d", port),
+	}
+	go s.supervise()
+	return s, nil
+}
+
+// supervise restarts rtl_tcp if it dies while the SDR is open. Readers
+// redial the new process and restore the tuning on their own.
+func (s *rtlSDR) supervise() {
+	defer close(s.exitc)
+	defer rtlPorts.put(s.port)
+	for {
+		err := s.proc.wait()
+		if s.ctx.Err() != nil {
+			return
+		}
+		log.Printf("rtl_tcp %s exited: %v; restarting", s.serialNumber, err)
+		for i := 0; ; i++ {
+			if s.proc, err = startRTLTCP(s.ctx, s.serialNumber, s.port); err == nil {
+				break
+			}
+			if errors.Is(err, ErrDeviceGone) || i == rtlRestarts || s.ctx.Err() != nil {
+				log.Printf("rtl_tcp %s: giving up: %v", s.serialNumber, err)
+				s.gone.Store(true)
+				s.cancel()
+				return
+			}
+			time.Sleep(time.Second)
+		}
+	}
 }
 
 func (s *rtlSDR) SetFreqCorrection(ppm uint32) error {
@@ -111,6 +188,34 @@ func (s *rtlSDR) SetFreqCorrection(ppm uint32) error {
 	return s.sdr.SetFreqCorrection(ppm)
 }
 
+func (s *rtlSDR) pinFreqCorrection(ppm uint32) error {
+	s.pinnedPPM = true
+	return s.SetFreqCorrection(ppm)
+}
+
+func (s *rtlSDR) SetGain(g GainConfig) error {
+	if err := s.initSDR(); err != nil {
+		return err
+	}
+	s.gain = g
+	return applyGain(s.sdr, g)
+}
+
+func applyGain(sdr *RTLTCPSDR, g GainConfig) error {
+	if err := sdr.SetGainMode(g.TunerAGC); err != nil {
+		return err
+	}
+	if !g.TunerAGC {
+		if err := sdr.SetGain(g.TenthDB); err != nil {
+			return err
+		}
+	}
+	if err := sdr.SetAGCMode(g.AGC); err != nil {
+		return err
+	}
+	return sdr.SetBiasTee(g.BiasTee)
+}
+
 func (s *rtlSDR) SetBand(b HzBand) error {
 	if b.Center < uint64(minFreqHz) || b.Center > uint64(maxFreqHz) {
 		return ErrFrequencyOutOfRange
@@ -122,18 +227,12 @@ func (s *rtlSDR) SetBand(b HzBand) error {
 		return err
 	}
 
-	// TODO: don't necessarily recalibrate; slow if not needed / ppm known.
-	if time.Since(s.lastCalibrateTime) > 5*time.Minute {
+	if !s.pinnedPPM && s.directSampling == DirectSamplingAuto && time.Since(s.lastCalibrateTime) > 5*time.Minute {
 		s.lastCalibrateTime = time.Now()
-		if s.lastCalibrateTime.IsZero() {
-			if err := s.sdr.SetAGCMode(true); err != nil {
-				return err
-			}
-		}
 		// Don't calibrate with NOAA if wired to HF antenna.
 		if b.Center > directSampMaxHz {
 			if err := Calibrate(s); err != nil {
-				return err
+				log.Printf("calibrating %s: %v", s.serialNumber, err)
 			}
 		}
 	}
@@ -155,6 +254,7 @@ func (s *rtlSDR) Info() SDRHWInfo {
 		Id: s.serialNumber,
 		SDRFormat: SDRFormat{
 			BitDepth:   8,
+			Format:     FormatCU8,
 			CenterHz:   uint64(s.lastCenter),
 			SampleRate: s.lastSampleRate,
 		},
@@ -162,15 +262,20 @@ func (s *rtlSDR) Info() SDRHWInfo {
 		MaxHz:         uint64(maxFreqHz),
 		MinSampleRate: minRate,
 		MaxSampleRate: maxRate,
+		Gain:          s.gain,
+		PPM:           int32(s.lastPPM),
 	}
 }
 
 func (s *rtlSDR) Close() error {
 	s.stop()
 	s.cancel()
-	s.fpty.Close()
-	err := s.cmd.Wait()
-	return err
+	if s.exitc == nil {
+		// Remote rtl_tcp server.
+		return nil
+	}
+	<-s.exitc
+	return nil
 }
 
 func (s *rtlSDR) band() HzBand {
@@ -187,7 +292,7 @@ func (s *rtlSDR) Reader() *MixerIQReader {
 	if s.sdr == nil {
 		return NewMixerIQReader(&eofReader{}, s.band())
 	} else if s.iqr == nil {
-		s.iqr = NewMixerIQReader(s.sdr, s.band())
+		s.iqr = NewMixerIQReader(&rtlConnReader{s, s.sdr}, s.band())
 	}
 	return s.iqr
 }
@@ -240,29 +345,18 @@ func (s *rtlSDR) setCenterFreq(cent uint32) error {
 	if cent < minFreqHz || cent > maxFreqHz {
 		return ErrFrequencyOutOfRange
 	}
-	if cent < directSampMaxHz {
-		if s.lastCenter == 0 || s.lastCenter >= directSampMaxHz {
-			if err := s.sdr.SetDirectSampling(2); err != nil {
-				return err
-			}
-		}
-		if err := s.sdr.SetGainMode(true); err != nil {
+	ds := s.directSamplingAt(cent)
+	if s.lastCenter == 0 || s.dsStale || ds != s.directSamplingAt(s.lastCenter) {
+		if err := s.sdr.SetDirectSampling(ds); err != nil {
 			return err
 		}
-		if err := s.sdr.SetGainByIndex(28); err != nil {
+		if err := applyGain(s.sdr, s.gain); err != nil {
 			return err
 		}
-	} else {
-		if s.lastCenter == 0 || s.lastCenter < directSampMaxHz {
-			if err := s.sdr.SetDirectSampling(0); err != nil {
-				return err
-			}
-			if err := s.sdr.SetGainMode(true); err != nil {
-				return err
-			}
-			if err := s.sdr.SetGainByIndex(28); err != nil {
-				return err
-			}
+		s.dsStale = false
+	} else if ds != 0 {
+		if err := applyGain(s.sdr, s.gain); err != nil {
+			return err
 		}
 	}
 	if s.lastCenter != cent {
@@ -274,6 +368,27 @@ func (s *rtlSDR) setCenterFreq(cent uint32) error {
 	return nil
 }
 
+// directSamplingAt is the rtl_tcp direct sampling state for a frequency.
+func (s *rtlSDR) directSamplingAt(cent uint32) uint32 {
+	if cent >= directSampMaxHz {
+		return 0
+	}
+	switch s.directSampling {
+	case DirectSamplingI:
+		return 1
+	case DirectSamplingAuto, DirectSamplingQ:
+		return 2
+	}
+	return 0
+}
+
+func (s *rtlSDR) setDirectSampling(ds DirectSampling) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.directSampling, s.dsStale = ds, true
+	return nil
+}
+
 func connect(ctx context.Context, addr string) (*RTLTCPSDR, error) {
 	var sdr *RTLTCPSDR
 	tcpAddr, err := net.ResolveTCPAddr("tcp4", addr)
@@ -282,7 +397,11 @@ func connect(ctx context.Context, addr string) (*RTLTCPSDR, error) {
 	}
 	for i := 0; i < 10; i++ {
 		sdr = &RTLTCPSDR{}
-		if err = sdr.Connect(tcpAddr); err != nil {
+		if err = sdr.Connect(tcpAddr); err == nil && !sdr.Info.HasTuner() {
+			sdr.Close()
+			err = fmt.Errorf("rtl_tcp at %s has no tuner", addr)
+		}
+		if err != nil {
 			fmt.Println(err)
 			sdr = nil
 		} else {
@@ -299,101 +418,11 @@ func connect(ctx context.Context, addr string) (*RTLTCPSDR, error) {
 func (s *rtlSDR) initSDR() (err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.gone.Load() {
+		return ErrDeviceGone
+	}
 	if s.sdr == nil {
 		err = s.resetConn()
 	}
 	return err
 }
-
-func rtlIdxs(ctx context.Context) (idxs []int, err error) {
-	cmd := exec.CommandContext(ctx, "rtl_eeprom")
-	p, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-	defer p.Close()
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-	defer cmd.Wait()
-	r := bufio.NewReader(p)
-	for {
-		l, err := r.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		if !strings.Contains(l, ":") {
-			break
-		}
-		ss := strings.Split(l, ":")
-		if strings.Contains(ss[0], "Found") {
-			continue
-		}
-		v := 0
-		if _, err := fmt.Sscanf(ss[0], "%d", &v); err != nil {
-			return nil, err
-		}
-		idxs = append(idxs, v)
-	}
-	return idxs, err
-}
-
-func rtlSDRList(ctx context.Context) (ret []SDRHWInfo, err error) {
-	idxs, err := rtlIdxs(ctx)
-	if err != nil {
-		return nil, err
-	}
-	// rtl_tcp will print the sn when listing all devices but not rtl_eeprom ?!
-	getSerial := func(idx int) (string, error) {
-		cmd := exec.CommandContext(ctx, "rtl_eeprom", "-d", fmt.Sprint(idx))
-		p, err := cmd.StderrPipe()
-		if err != nil {
-			return "", err
-		}
-		defer p.Close()
-		if err := cmd.Start(); err != nil {
-			return "", err
-		}
-		defer cmd.Wait()
-		r := bufio.NewReader(p)
-		for {
-			l, err := r.ReadString('\n')
-			if err != nil {
-				return "", err
-			}
-			if !strings.Contains(l, "Serial number:") {
-				continue
-			}
-			ss := strings.Split(l, ":")
-			if len(ss) != 2 {
-				continue
-			}
-			return strings.TrimSpace(ss[1]), nil
-		}
-		return "", io.EOF
-	}
-	for _, v := range idxs {
-		s, err := getSerial(v)
-		if err == io.EOF {
-			log.Println("failed enumerating", v)
-			continue
-		}
-		if err != nil {
-			return nil, err
-		}
-		info := SDRHWInfo{
-			Id:            s,
-			MinHz:         uint64(minFreqHz),
-			MaxHz:         uint64(maxFreqHz),
-			MinSampleRate: minRate,
-			MaxSampleRate: maxRate,
-			SDRFormat: SDRFormat{
-				BitDepth:   8,
-				CenterHz:   0,
-				SampleRate: 0,
-			},
-		}
-		ret = append(ret, info)
-	}
-	return ret, nil
-}
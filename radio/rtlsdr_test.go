@@ -22,9 +22,7 @@ func TestRTLBadRate(t *testing.T) {
 }
 
 func TestRTLList(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
-	defer cancel()
-	sdrs, err := rtlSDRList(ctx)
+	sdrs, err := DefaultDiscovery.List()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,3 +43,36 @@ func TestRTLHammerOpenClose(t *testing.T) {
 		cancel()
 	}
 }
+
+// Test reads resume after rtl_tcp crashes.
+func TestRTLRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
+	defer cancel()
+	rtlsdr, err := newRTLSDR(ctx, testRadioSerial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtlsdr.Close()
+	if err := rtlsdr.SetFreqCorrection(0); err != nil {
+		t.Fatal(err)
+	}
+	band := HzBand{Center: 100 * 1e6, Width: 240000}
+	if err := rtlsdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	sigc := rtlsdr.Reader().BatchStream64(ctx, 24000, 20)
+	<-sigc
+	if err := rtlsdr.proc.cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	n := 1
+	for range sigc {
+		n++
+	}
+	if n != 20 {
+		t.Fatalf("expected 20 batches across restart, got %d", n)
+	}
+	if info := rtlsdr.Info(); info.HzBand() != band {
+		t.Fatalf("expected tuning %+v after restart, got %+v", band, info.HzBand())
+	}
+}
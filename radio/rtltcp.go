@@ -3,6 +3,7 @@ package radio
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 )
 
@@ -43,58 +44,87 @@ type DongleInfo struct {
 	GainCount uint32 // Useful for setting gain by index
 }
 
+// NewDongleInfo describes an R820T dongle for serving rtl_tcp clients.
+func NewDongleInfo(gainCount uint32) DongleInfo {
+	return DongleInfo{Magic: dongleMagic, Tuner: tunerR820T, GainCount: gainCount}
+}
+
 // Valid checks the received magic number matches the expected byte string 'RTL0'.
 func (d DongleInfo) Valid() bool {
 	return d.Magic == dongleMagic
 }
 
-type command struct {
-	command   uint8
+// Tuner types defined in rtl-sdr.h
+const (
+	tunerUnknown = iota
+	tunerE4000
+	tunerFC0012
+	tunerFC0013
+	tunerFC2580
+	tunerR820T
+	tunerR828D
+)
+
+// HasTuner checks the dongle reported a tuner rtl-sdr knows how to drive.
+func (d DongleInfo) HasTuner() bool {
+	return d.Tuner != tunerUnknown && d.Tuner <= tunerR828D
+}
+
+// Command is a client request on an rtl_tcp connection.
+type Command struct {
+	Command   uint8
 	Parameter uint32
 }
 
 // Command constants defined in rtl_tcp.c
 const (
-	centerFreq = iota + 1
-	sampleRate
-	tunerGainMode
-	tunerGain
-	freqCorrection
-	tunerIfGain
-	testMode
-	agcMode
-	directSampling
-	offsetTuning
-	rtlXtalFreq
-	tunerXtalFreq
-	gainByIndex
+	CmdCenterFreq = iota + 1
+	CmdSampleRate
+	CmdTunerGainMode
+	CmdTunerGain
+	CmdFreqCorrection
+	CmdTunerIfGain
+	CmdTestMode
+	CmdAGCMode
+	CmdDirectSampling
+	CmdOffsetTuning
+	CmdRTLXtalFreq
+	CmdTunerXtalFreq
+	CmdGainByIndex
+	CmdBiasTee
 )
 
+// ReadCommand reads the next command sent by an rtl_tcp client.
+func ReadCommand(r io.Reader) (cmd Command, err error) {
+	err = binary.Read(r, binary.BigEndian, &cmd)
+	return cmd, err
+}
+
 func (sdr *RTLTCPSDR) do(cmd uint8, v uint32) error {
-	return binary.Write(sdr.TCPConn, binary.BigEndian, command{cmd, v})
+	return binary.Write(sdr.TCPConn, binary.BigEndian, Command{cmd, v})
 }
 
 // Set the center frequency in Hz.
 func (sdr *RTLTCPSDR) SetCenterFreq(freq uint32) error {
-	return sdr.do(centerFreq, freq)
+	return sdr.do(CmdCenterFreq, freq)
 }
 
 // Set the sample rate in Hz.
 func (sdr *RTLTCPSDR) SetSampleRate(rate uint32) error {
-	return sdr.do(sampleRate, rate)
+	return sdr.do(CmdSampleRate, rate)
 }
 
 // Set gain in tenths of dB. (197 => 19.7dB)
 func (sdr *RTLTCPSDR) SetGain(gain uint32) error {
-	return sdr.do(tunerGain, gain)
+	return sdr.do(CmdTunerGain, gain)
 }
 
 // Set the Tuner AGC, true to enable.
 func (sdr *RTLTCPSDR) SetGainMode(state bool) error {
 	if state {
-		return sdr.do(tunerGainMode, 0)
+		return sdr.do(CmdTunerGainMode, 0)
 	}
-	return sdr.do(tunerGainMode, 1)
+	return sdr.do(CmdTunerGainMode, 1)
 }
 
 // Set gain by index, must be <= DongleInfo.GainCount
@@ -102,54 +132,62 @@ func (sdr *RTLTCPSDR) SetGainByIndex(idx uint32) error {
 	if idx > sdr.Info.GainCount {
 		return fmt.Errorf("invalid gain index: %d", idx)
 	}
-	return sdr.do(gainByIndex, idx)
+	return sdr.do(CmdGainByIndex, idx)
 }
 
 // Set frequency correction in ppm.
 func (sdr *RTLTCPSDR) SetFreqCorrection(ppm uint32) error {
-	return sdr.do(freqCorrection, ppm)
+	return sdr.do(CmdFreqCorrection, ppm)
 }
 
 // Set tuner intermediate frequency stage and gain.
 func (sdr *RTLTCPSDR) SetTunerIfGain(stage, gain uint16) error {
-	return sdr.do(tunerIfGain, (uint32(stage)<<16)|uint32(gain))
+	return sdr.do(CmdTunerIfGain, (uint32(stage)<<16)|uint32(gain))
 }
 
 // Set test mode, true for enabled.
 func (sdr *RTLTCPSDR) SetTestMode(state bool) error {
 	if state {
-		return sdr.do(testMode, 1)
+		return sdr.do(CmdTestMode, 1)
 	}
-	return sdr.do(testMode, 0)
+	return sdr.do(CmdTestMode, 0)
 }
 
 // Set RTL AGC mode, true for enabled.
 func (sdr *RTLTCPSDR) SetAGCMode(state bool) error {
 	if state {
-		return sdr.do(agcMode, 1)
+		return sdr.do(CmdAGCMode, 1)
 	}
-	return sdr.do(agcMode, 0)
+	return sdr.do(CmdAGCMode, 0)
 }
 
 // Set direct sampling mode. 0 = disabled, 1 = i-branch, 2 = q-branch, 3 = direct mod.
 func (sdr *RTLTCPSDR) SetDirectSampling(state uint32) error {
-	return sdr.do(directSampling, state)
+	return sdr.do(CmdDirectSampling, state)
 }
 
 // Set offset tuning, true for enabled.
 func (sdr *RTLTCPSDR) SetOffsetTuning(state bool) error {
 	if state {
-		return sdr.do(offsetTuning, 1)
+		return sdr.do(CmdOffsetTuning, 1)
+	}
+	return sdr.do(CmdOffsetTuning, 0)
+}
+
+// Set bias tee power on the antenna port, true for enabled.
+func (sdr *RTLTCPSDR) SetBiasTee(state bool) error {
+	if state {
+		return sdr.do(CmdBiasTee, 1)
 	}
-	return sdr.do(offsetTuning, 0)
+	return sdr.do(CmdBiasTee, 0)
 }
 
 // Set RTL xtal frequency.
 func (sdr *RTLTCPSDR) SetRTLXtalFreq(freq uint32) error {
-	return sdr.do(rtlXtalFreq, freq)
+	return sdr.do(CmdRTLXtalFreq, freq)
 }
 
 // Set tuner xtal frequency.
 func (sdr *RTLTCPSDR) SetTunerXtalFreq(freq uint32) error {
-	return sdr.do(tunerXtalFreq, freq)
+	return sdr.do(CmdTunerXtalFreq, freq)
 }
@@ -8,6 +8,10 @@ import (
 type ScanConfig struct {
 	CenterMHz   float64
 	MinWidthMHz float64
+	// Spectral configures the power estimate; zero uses the defaults.
+	Spectral SpectralConfig
+	// CFAR configures signal detection; zero uses the defaults.
+	CFAR CFARConfig
 }
 
 var ErrBadSampleRate = errors.New("bad sample rate")
@@ -20,20 +24,28 @@ func Scan(sdr SDR, cfg ScanConfig) (ret []FreqBand) {
 	if err := sdr.SetBand(hzb); err != nil {
 		panic(err)
 	}
-	ret, _ = ScanIQReader(sdr.Reader(), cfg.MinWidthMHz*1e6)
+	ret, _ = scanIQReader(sdr.Reader(), cfg.MinWidthMHz*1e6, cfg.Spectral, cfg.CFAR)
 	return ret
 }
 
 func ScanIQReader(iqr *MixerIQReader, minWidthHz float64) (ret []FreqBand, err error) {
+	return scanIQReader(iqr, minWidthHz, DefaultSpectralConfig, DefaultCFARConfig)
+}
+
+func scanIQReader(iqr *MixerIQReader, minWidthHz float64, cfg SpectralConfig, ccfg CFARConfig) (ret []FreqBand, err error) {
 	if iqr.Width != uint64(scanSampleRate) {
 		return nil, ErrBadSampleRate
 	}
-	sp := NewSpectralPower(iqr.ToMHz(), scanWindowSamples, 50)
+	if cfg == (SpectralConfig{}) {
+		cfg = DefaultSpectralConfig
+	}
+	sp := NewSpectralPowerConfig(iqr.ToMHz(), scanWindowSamples, 50, cfg)
 	if err = sp.Measure(iqr.Batch64(scanWindowSamples, 50)); err != nil {
 		return nil, err
 	}
 	spurs := sp.Spurs()
-	for _, fb := range sp.Bands() {
+	for _, det := range sp.Detect(ccfg) {
+		fb := det.FreqBand
 		if fb.Width <= minWidthHz/1e6 {
 			continue
 		}
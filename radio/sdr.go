@@ -3,15 +3,18 @@ package radio
 import (
 	"context"
 	"errors"
-	"log"
+	"strings"
 )
 
 var ErrRateOutOfRange = errors.New("sample rate out of range")
 var ErrFrequencyOutOfRange = errors.New("frequency out of range")
+var ErrUnsupported = errors.New("unsupported by sdr")
+var ErrDeviceGone = errors.New("sdr device gone")
 
 type SDR interface {
 	SetBand(b HzBand) error
 	SetFreqCorrection(ppm uint32) error
+	SetGain(g GainConfig) error
 	Info() SDRHWInfo
 	Close() error
 	Reader() *MixerIQReader
@@ -21,12 +24,31 @@ type SDRFormat struct {
 	BitDepth   uint   `json:"bit_depth"`
 	CenterHz   uint64 `json:"center_hz"`
 	SampleRate uint32 `json:"sample_rate"`
+	// Format is the sample encoding; empty means cu8.
+	Format SampleFormat `json:"format,omitempty"`
 }
 
 func (s *SDRFormat) HzBand() HzBand {
 	return HzBand{Center: s.CenterHz, Width: uint64(s.SampleRate)}
 }
 
+// GainConfig controls the receiver gain stages.
+type GainConfig struct {
+	// TunerAGC lets the tuner pick its own gain; TenthDB is ignored if set.
+	TunerAGC bool `json:"tuner_agc"`
+	// TenthDB is the manual tuner gain in tenths of dB. (197 => 19.7dB)
+	TenthDB uint32 `json:"gain_tenth_db"`
+	// AGC enables the RTL2832 digital AGC.
+	AGC bool `json:"agc"`
+	// BiasTee powers an active antenna through the antenna port.
+	BiasTee bool `json:"bias_tee"`
+}
+
+func (g GainConfig) DB() float64 { return float64(g.TenthDB) / 10 }
+
+// DefaultGain is full gain on an R820T; tuners pick their nearest gain.
+var DefaultGain = GainConfig{TenthDB: 496}
+
 type SDRHWInfo struct {
 	Id string `json:"id"`
 
@@ -35,40 +57,34 @@ type SDRHWInfo struct {
 	MinSampleRate uint32 `json:"min_sample_rate"`
 	MaxSampleRate uint32 `json:"max_sample_rate"`
 
-	SDRFormat
-}
+	Gain GainConfig `json:"gain"`
+	// PPM is the applied frequency correction.
+	PPM int32 `json:"ppm"`
+	// Drift is the recent frequency error history, if monitored.
+	Drift []DriftSample `json:"drift,omitempty"`
+	// InUse is set when a daemon holds the radio open.
+	InUse bool `json:"in_use,omitempty"`
+	// Profile is the radio's front end, if configured.
+	Profile *DeviceProfile `json:"profile,omitempty"`
 
-func Calibrate(s SDR) error {
-	for {
-		ppm, err := FindPPM(s)
-		if err != nil {
-			return err
-		}
-		log.Println("measured ppm", ppm)
-		if ppm < 1.0 {
-			break
-		}
-		if err := s.SetFreqCorrection(uint32(ppm)); err != nil {
-			return err
-		}
-		ppm, err = FindPPM(s)
-		if err != nil {
-			return err
-		}
-		log.Println("new ppm", ppm)
-		if ppm < 2.0 {
-			break
-		} else if err := s.SetFreqCorrection(0); err != nil {
-			return err
-		}
-	}
-	return nil
+	SDRFormat
 }
 
 func NewSDR(ctx context.Context) (SDR, error) { return newRTLSDR(ctx, "0") }
 
-func NewSDRWithSerial(ctx context.Context, ser string) (SDR, error) { return newRTLSDR(ctx, ser) }
+func NewSDRWithSerial(ctx context.Context, ser string) (SDR, error) {
+	if strings.HasPrefix(ser, simPrefix) {
+		return newSimSDRWithSerial(ctx, ser)
+	} else if strings.HasPrefix(ser, filePrefix) {
+		return newFileSDRWithSerial(ctx, ser)
+	} else if strings.HasPrefix(ser, tcpPrefix) {
+		return newRemoteRTLSDR(ctx, ser)
+	} else if strings.HasPrefix(ser, execPrefix) {
+		return newExecSDRWithSerial(ctx, ser)
+	}
+	return newRTLSDR(ctx, ser)
+}
 
 func SDRList(ctx context.Context) ([]SDRHWInfo, error) {
-	return rtlSDRList(ctx)
+	return DefaultDiscovery.SDRList()
 }
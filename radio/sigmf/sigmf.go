@@ -0,0 +1,134 @@
+// Package sigmf reads and writes SigMF recording metadata.
+//
+// See https://github.com/sigmf/SigMF/blob/main/sigmf-spec.md
+package sigmf
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	Version = "1.0.0"
+	DataExt = ".sigmf-data"
+	MetaExt = ".sigmf-meta"
+)
+
+// Meta is the contents of a .sigmf-meta file.
+type Meta struct {
+	Global      Global       `json:"global"`
+	Captures    []Capture    `json:"captures"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+type Global struct {
+	// Datatype is the sample encoding (e.g., "cu8", "ci16_le", "cf32_le").
+	Datatype    string      `json:"core:datatype"`
+	SampleRate  float64     `json:"core:sample_rate,omitempty"`
+	Version     string      `json:"core:version"`
+	Description string      `json:"core:description,omitempty"`
+	Recorder    string      `json:"core:recorder,omitempty"`
+	HW          string      `json:"core:hw,omitempty"`
+	Extensions  []Extension `json:"core:extensions,omitempty"`
+
+	// Serial is the serial number of the receiving radio.
+	Serial string `json:"nicerx:serial,omitempty"`
+	// PPM is the frequency correction applied by the receiving radio.
+	PPM float64 `json:"nicerx:ppm,omitempty"`
+}
+
+type Extension struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Optional bool   `json:"optional"`
+}
+
+// NicerxExtension declares the "nicerx:" fields.
+var NicerxExtension = Extension{Name: "nicerx", Version: "1.0.0", Optional: true}
+
+// Capture describes the tuning starting at some sample in the recording.
+type Capture struct {
+	SampleStart uint64  `json:"core:sample_start"`
+	Frequency   float64 `json:"core:frequency,omitempty"`
+	// Datetime is the ISO-8601 UTC time of the capture's first sample.
+	Datetime string `json:"core:datetime,omitempty"`
+}
+
+type Annotation struct {
+	SampleStart   uint64  `json:"core:sample_start"`
+	SampleCount   uint64  `json:"core:sample_count,omitempty"`
+	FreqLowerEdge float64 `json:"core:freq_lower_edge,omitempty"`
+	FreqUpperEdge float64 `json:"core:freq_upper_edge,omitempty"`
+	Label         string  `json:"core:label,omitempty"`
+	Comment       string  `json:"core:comment,omitempty"`
+}
+
+// NewCapture starts a capture at sample start, tuned to hz at time t.
+func NewCapture(start uint64, hz float64, t time.Time) Capture {
+	c := Capture{SampleStart: start, Frequency: hz}
+	if !t.IsZero() {
+		c.Datetime = t.UTC().Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// Time parses the capture's datetime, if any.
+func (c *Capture) Time() (time.Time, error) {
+	if c.Datetime == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, c.Datetime)
+}
+
+// IsSigMF checks if a path names a SigMF data or metadata file.
+func IsSigMF(path string) bool {
+	return strings.HasSuffix(path, DataExt) || strings.HasSuffix(path, MetaExt)
+}
+
+func basePath(path string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(path, DataExt), MetaExt)
+}
+
+// MetaPath is the metadata file paired with a SigMF path.
+func MetaPath(path string) string { return basePath(path) + MetaExt }
+
+// DataPath is the data file paired with a SigMF path.
+func DataPath(path string) string { return basePath(path) + DataExt }
+
+// ReadFile reads the metadata paired with a SigMF path.
+func ReadFile(path string) (*Meta, error) {
+	b, err := os.ReadFile(MetaPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// WriteFile writes the metadata paired with a SigMF path.
+func (m *Meta) WriteFile(path string) error {
+	if m.Captures == nil {
+		m.Captures = []Capture{}
+	}
+	if m.Annotations == nil {
+		m.Annotations = []Annotation{}
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(path), append(b, '\n'), 0644)
+}
+
+// Frequency is the center frequency of the first capture.
+func (m *Meta) Frequency() float64 {
+	if len(m.Captures) == 0 {
+		return 0
+	}
+	return m.Captures[0].Frequency
+}
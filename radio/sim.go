@@ -0,0 +1,328 @@
+package radio
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const simPrefix = "sim:"
+
+// How often the simulator emits a block of samples.
+const simTick = 20 * time.Millisecond
+
+type SimSignalType string
+
+const (
+	SimCarrier SimSignalType = "carrier"
+	SimFM      SimSignalType = "fm"
+	SimAM      SimSignalType = "am"
+	SimOOK     SimSignalType = "ook"
+)
+
+// SimSignal is a synthetic transmitter in a simulated scene.
+type SimSignal struct {
+	Type SimSignalType `json:"type"`
+	Hz   uint64        `json:"hz"`
+	// DB is the signal power in dB relative to full scale.
+	DB float64 `json:"db"`
+	// ToneHz is the modulating tone for FM/AM and the keying rate for OOK.
+	ToneHz float64 `json:"tone_hz"`
+	// DeviationHz is the peak deviation for FM.
+	DeviationHz float64 `json:"deviation_hz"`
+	// Stereo adds a 19kHz pilot to FM at a tenth of the deviation.
+	Stereo bool `json:"stereo"`
+	// Depth is the AM modulation depth in [0, 1].
+	Depth float64 `json:"depth"`
+	// BurstMs and PeriodMs key the signal on for BurstMs out of every
+	// PeriodMs; the signal is continuous if PeriodMs is zero.
+	BurstMs  int `json:"burst_ms"`
+	PeriodMs int `json:"period_ms"`
+}
+
+// SimScene describes what a simulated SDR receives.
+type SimScene struct {
+	// NoiseDB is the noise power in dB relative to full scale.
+	NoiseDB float64 `json:"noise_db"`
+	// PPM is the crystal error of the simulated dongle.
+	PPM     int32       `json:"ppm"`
+	Signals []SimSignal `json:"signals"`
+}
+
+// DefaultSimScene has a few broadcast FM stations, NOAA weather carriers for
+// calibration, an AM airband voice channel, and an OOK keyfob.
+var DefaultSimScene = SimScene{
+	NoiseDB: -45,
+	Signals: []SimSignal{
+		{Type: SimFM, Hz: 88500000, DB: -20, ToneHz: 1000, DeviationHz: 75000},
+		{Type: SimFM, Hz: 100100000, DB: -15, ToneHz: 440, DeviationHz: 75000, Stereo: true},
+		{Type: SimFM, Hz: 101100000, DB: -25, ToneHz: 2000, DeviationHz: 75000},
+		{Type: SimAM, Hz: 121500000, DB: -30, ToneHz: 1000, Depth: 0.8, BurstMs: 2000, PeriodMs: 5000},
+		{Type: SimCarrier, Hz: 144390000, DB: -35},
+		{Type: SimCarrier, Hz: 162400000, DB: -25},
+		{Type: SimCarrier, Hz: 162550000, DB: -20},
+		{Type: SimOOK, Hz: 433920000, DB: -20, ToneHz: 2000, BurstMs: 100, PeriodMs: 1000},
+	},
+}
+
+type simSDR struct {
+	ser   string
+	scene SimScene
+
+	band HzBand
+	ppm  atomic.Int32
+	gain atomic.Pointer[GainConfig]
+
+	iqr    *MixerIQReader
+	pr     *io.PipeReader
+	cancel context.CancelFunc
+	donec  <-chan struct{}
+
+	ctx context.Context
+	mu  sync.RWMutex
+}
+
+// NewSimSDR creates a simulated SDR that receives the given scene.
+func NewSimSDR(ctx context.Context, ser string, scene SimScene) SDR {
+	s := &simSDR{ser: ser, scene: scene, ctx: ctx}
+	g := DefaultGain
+	s.gain.Store(&g)
+	return s
+}
+
+func newSimSDRWithSerial(ctx context.Context, ser string) (SDR, error) {
+	scene := DefaultSimScene
+	if p := strings.TrimPrefix(ser, simPrefix); strings.HasSuffix(p, ".json") {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		scene = SimScene{}
+		if err := json.Unmarshal(b, &scene); err != nil {
+			return nil, err
+		}
+	}
+	return NewSimSDR(ctx, ser, scene), nil
+}
+
+func simSDRList() []SDRHWInfo {
+	return []SDRHWInfo{NewSimSDR(context.TODO(), simPrefix, DefaultSimScene).Info()}
+}
+
+func (s *simSDR) SetBand(b HzBand) error {
+	if b.Center < uint64(minFreqHz) || b.Center > uint64(maxFreqHz) {
+		return ErrFrequencyOutOfRange
+	}
+	if !isValidRate(uint32(b.Width)) {
+		return ErrRateOutOfRange
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	s.band = b
+	pr, pw := io.Pipe()
+	cctx, cancel := context.WithCancel(s.ctx)
+	donec := make(chan struct{})
+	s.pr, s.cancel, s.donec = pr, cancel, donec
+	go func() {
+		defer close(donec)
+		s.run(cctx, pw, b)
+	}()
+	return nil
+}
+
+func (s *simSDR) SetFreqCorrection(ppm uint32) error {
+	s.ppm.Store(int32(ppm))
+	return nil
+}
+
+func (s *simSDR) SetGain(g GainConfig) error {
+	s.gain.Store(&g)
+	return nil
+}
+
+// gainScale is the amplitude change from the gain the scene assumes.
+func (s *simSDR) gainScale() float64 {
+	g := s.gain.Load()
+	if g.TunerAGC {
+		return 1
+	}
+	return math.Pow(10, (float64(g.TenthDB)-float64(DefaultGain.TenthDB))/200)
+}
+
+func (s *simSDR) Info() SDRHWInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SDRHWInfo{
+		Id: s.ser,
+		SDRFormat: SDRFormat{
+			BitDepth:   8,
+			Format:     FormatCU8,
+			CenterHz:   s.band.Center,
+			SampleRate: uint32(s.band.Width),
+		},
+		MinHz:         uint64(minFreqHz),
+		MaxHz:         uint64(maxFreqHz),
+		MinSampleRate: minRate,
+		MaxSampleRate: maxRate,
+		Gain:          *s.gain.Load(),
+		PPM:           s.ppm.Load(),
+	}
+}
+
+func (s *simSDR) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stop()
+	return nil
+}
+
+func (s *simSDR) Reader() *MixerIQReader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pr == nil {
+		return NewMixerIQReader(&eofReader{}, s.band)
+	} else if s.iqr == nil {
+		s.iqr = NewMixerIQReader(s.pr, s.band)
+	}
+	return s.iqr
+}
+
+func (s *simSDR) stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.pr.Close()
+	<-s.donec
+	s.pr, s.iqr, s.cancel, s.donec = nil, nil, nil, nil
+}
+
+// clockScale is how fast the simulated dongle's crystal runs given its
+// error and the configured correction. The tuner and the sample clock both
+// run off the crystal.
+func (s *simSDR) clockScale() float64 {
+	return 1 + float64(s.scene.PPM-s.ppm.Load())/1e6
+}
+
+type simEmitter struct {
+	SimSignal
+	amp   float64
+	osc   complex128
+	tone  complex128
+	pilot complex128
+}
+
+func (s *simSDR) run(ctx context.Context, pw *io.PipeWriter, b HzBand) {
+	defer pw.Close()
+	fs := float64(b.Width)
+	var ems []*simEmitter
+	for _, sig := range s.scene.Signals {
+		if math.Abs(float64(sig.Hz)-float64(b.Center)) >= fs/2 {
+			continue
+		}
+		ems = append(ems, &simEmitter{
+			SimSignal: sig,
+			amp:       math.Pow(10, sig.DB/20),
+			osc:       1,
+			tone:      1,
+			pilot:     1,
+		})
+	}
+	// Noise power is split evenly between I and Q.
+	noiseAmp := math.Pow(10, s.scene.NoiseDB/20) / math.Sqrt2
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	chunk := int(fs * simTick.Seconds())
+	buf, samps := make([]byte, 2*chunk), make([]complex128, chunk)
+	ticker := time.NewTicker(simTick)
+	defer ticker.Stop()
+	n := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		k := s.clockScale()
+		lo := float64(b.Center) * k
+		for i := range samps {
+			samps[i] = complex(noiseAmp*rng.NormFloat64(), noiseAmp*rng.NormFloat64())
+		}
+		for _, em := range ems {
+			em.mix(samps, float64(em.Hz)-lo, fs*k, n)
+		}
+		scale := s.gainScale()
+		for i, v := range samps {
+			buf[2*i], buf[2*i+1] = simU8(scale*real(v)), simU8(scale*imag(v))
+		}
+		n += chunk
+		if _, err := pw.Write(buf); err != nil {
+			return
+		}
+	}
+}
+
+// mix adds the emitter's signal at offHz from the tuned center into samps,
+// where n is the absolute index of the first sample.
+func (em *simEmitter) mix(samps []complex128, offHz, fs float64, n int) {
+	rot := cmplx.Rect(1, 2*math.Pi*offHz/fs)
+	toneRot := cmplx.Rect(1, 2*math.Pi*em.ToneHz/fs)
+	pilotRot := cmplx.Rect(1, 2*math.Pi*pilotHz/fs)
+	for i := range samps {
+		if !em.keyed(n+i, fs) {
+			em.osc *= rot
+			continue
+		}
+		em.tone *= toneRot
+		v := em.osc
+		switch em.Type {
+		case SimFM:
+			dev := em.DeviationHz * imag(em.tone)
+			if em.Stereo {
+				em.pilot *= pilotRot
+				dev = 0.9*dev + 0.1*em.DeviationHz*imag(em.pilot)
+			}
+			fmRot := cmplx.Rect(1, 2*math.Pi*dev/fs)
+			em.osc *= fmRot
+		case SimAM:
+			v *= complex(1+em.Depth*imag(em.tone), 0)
+		case SimOOK:
+			if imag(em.tone) < 0 {
+				v = 0
+			}
+		}
+		samps[i] += complex(em.amp, 0) * v
+		em.osc *= rot
+	}
+	// Keep oscillators from drifting off the unit circle.
+	em.osc /= complex(cmplx.Abs(em.osc), 0)
+	em.tone /= complex(cmplx.Abs(em.tone), 0)
+	em.pilot /= complex(cmplx.Abs(em.pilot), 0)
+}
+
+func (em *simEmitter) keyed(n int, fs float64) bool {
+	if em.PeriodMs <= 0 {
+		return true
+	}
+	ms := int(float64(n)*1000/fs) % em.PeriodMs
+	return ms < em.BurstMs
+}
+
+func simU8(v float64) byte {
+	v = v*128 + 127
+	if v < 0 {
+		return 0
+	} else if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
@@ -0,0 +1,110 @@
+package radio
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func simPeakHz(t *testing.T, sdr SDR, band HzBand) float64 {
+	bins := 8192
+	sp := NewSpectralPower(band.ToMHz(), bins, 20)
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	if err := sp.Measure(sdr.Reader().BatchStream64(ctx, bins, 0)); err != nil {
+		t.Fatal(err)
+	}
+	peak, peakIdx := sp.Average()[0], 0
+	for i, v := range sp.Average() {
+		if v > peak {
+			peak, peakIdx = v, i
+		}
+	}
+	return float64(peakIdx-bins/2) * float64(band.Width) / float64(bins)
+}
+
+func TestSimCarrier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	scene := SimScene{
+		NoiseDB: -50,
+		PPM:     50,
+		Signals: []SimSignal{{Type: SimCarrier, Hz: 100200000, DB: -10}},
+	}
+	sdr := NewSimSDR(ctx, "sim:test", scene)
+	defer sdr.Close()
+
+	band := HzBand{Center: 100000000, Width: 1024000}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	binHz := float64(band.Width) / 8192
+
+	// 50ppm at 100MHz tunes 5kHz high, so the carrier appears 5kHz low.
+	if hz := simPeakHz(t, sdr, band); hz < 195000-2*binHz || hz > 195000+2*binHz {
+		t.Fatalf("expected uncorrected carrier at 195kHz, got %v", hz)
+	}
+	if err := sdr.SetFreqCorrection(50); err != nil {
+		t.Fatal(err)
+	}
+	if hz := simPeakHz(t, sdr, band); hz < 200000-2*binHz || hz > 200000+2*binHz {
+		t.Fatalf("expected corrected carrier at 200kHz, got %v", hz)
+	}
+}
+
+func TestSimSerial(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	sdr, err := NewSDRWithSerial(ctx, "sim:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	if err := sdr.SetBand(HzBand{Center: 100000000, Width: 24000}); err != ErrRateOutOfRange {
+		t.Fatalf("expected bad rate error, got %v", err)
+	}
+	if id := sdr.Info().Id; id != "sim:abc" {
+		t.Fatalf("expected sim:abc, got %q", id)
+	}
+}
+
+func TestSimGain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	scene := SimScene{
+		NoiseDB: -50,
+		Signals: []SimSignal{{Type: SimCarrier, Hz: 100200000, DB: -10}},
+	}
+	sdr := NewSimSDR(ctx, "sim:test", scene)
+	defer sdr.Close()
+	band := HzBand{Center: 100000000, Width: 1024000}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	peakDB := func() float64 {
+		sp := NewSpectralPower(band.ToMHz(), 1024, 20)
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := sp.Measure(sdr.Reader().BatchStream64(ctx, 1024, 0)); err != nil {
+			t.Fatal(err)
+		}
+		peak := sp.Average()[0]
+		for _, v := range sp.Average() {
+			peak = math.Max(peak, v)
+		}
+		return peak
+	}
+
+	before := peakDB()
+	g := GainConfig{TenthDB: DefaultGain.TenthDB - 100}
+	if err := sdr.SetGain(g); err != nil {
+		t.Fatal(err)
+	}
+	if got := sdr.Info().Gain; got != g {
+		t.Fatalf("expected gain %+v, got %+v", g, got)
+	}
+	if d := before - peakDB(); d < 9 || d > 11 {
+		t.Fatalf("expected carrier 10dB lower, got %.1fdB", d)
+	}
+}
@@ -0,0 +1,271 @@
+package radio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SweepConfig steps a radio across a range wider than its sample rate.
+type SweepConfig struct {
+	// Range is the band to sweep.
+	Range HzBand
+	// SampleRate is the rate each hop is captured at.
+	SampleRate uint32
+	// Bins is the FFT size of each hop.
+	Bins int
+	// Overlap is the fraction of each hop's band dropped at the edges,
+	// where the radio's filters roll off; neighboring hops overlap by it.
+	Overlap float64
+	// Dwell is how long each hop integrates.
+	Dwell time.Duration
+	// Spectral configures the power estimate; zero uses the defaults.
+	Spectral SpectralConfig
+}
+
+var DefaultSweepConfig = SweepConfig{
+	SampleRate: 2048000,
+	Bins:       1024,
+	Overlap:    0.25,
+	Dwell:      100 * time.Millisecond,
+	Spectral:   DefaultSpectralConfig,
+}
+
+var ErrEmptySweep = errors.New("empty sweep range")
+
+func (cfg SweepConfig) withDefaults() SweepConfig {
+	d := DefaultSweepConfig
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = d.SampleRate
+	}
+	if cfg.Bins <= 0 {
+		cfg.Bins = d.Bins
+	}
+	if cfg.Overlap <= 0 || cfg.Overlap >= 0.9 {
+		cfg.Overlap = d.Overlap
+	}
+	if cfg.Dwell <= 0 {
+		cfg.Dwell = d.Dwell
+	}
+	if cfg.Spectral == (SpectralConfig{}) {
+		cfg.Spectral = d.Spectral
+	}
+	return cfg
+}
+
+// SweepSegment is the kept part of one hop's spectrum.
+type SweepSegment struct {
+	Time time.Time
+	// LoHz is the lower edge of the first bin.
+	LoHz  float64
+	BinHz float64
+	// Samples is the number of samples integrated.
+	Samples int
+	// DB is the power of each bin in dBFS/Hz.
+	DB []float64
+}
+
+func (s *SweepSegment) HiHz() float64 { return s.LoHz + float64(len(s.DB))*s.BinHz }
+
+// WriteCSV writes the segment as an rtl_power row.
+func (s *SweepSegment) WriteCSV(w io.Writer) error {
+	row := fmt.Sprintf("%s, %.0f, %.0f, %.2f, %d",
+		s.Time.Format("2006-01-02, 15:04:05"), s.LoHz, s.HiHz(), s.BinHz, s.Samples)
+	for _, db := range s.DB {
+		row += fmt.Sprintf(", %.2f", db)
+	}
+	_, err := io.WriteString(w, row+"\n")
+	return err
+}
+
+// ReadSweepCSV reads rtl_power CSV. A new sweep starts whenever a row
+// doesn't continue upward from the last one.
+func ReadSweepCSV(r io.Reader) (ret []*SweepResult, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		seg, err := parseSweepRow(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if n := len(ret); n == 0 || seg.LoHz <= ret[n-1].Segments[len(ret[n-1].Segments)-1].LoHz {
+			ret = append(ret, &SweepResult{})
+		}
+		sr := ret[len(ret)-1]
+		sr.Segments = append(sr.Segments, seg)
+	}
+	return ret, sc.Err()
+}
+
+func parseSweepRow(row string) (*SweepSegment, error) {
+	f := strings.Split(row, ",")
+	if len(f) < 7 {
+		return nil, fmt.Errorf("expected at least 7 fields, got %d", len(f))
+	}
+	for i := range f {
+		f[i] = strings.TrimSpace(f[i])
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", f[0]+" "+f[1], time.Local)
+	if err != nil {
+		return nil, err
+	}
+	seg := &SweepSegment{Time: t, DB: make([]float64, len(f)-6)}
+	if seg.LoHz, err = strconv.ParseFloat(f[2], 64); err != nil {
+		return nil, err
+	}
+	if seg.BinHz, err = strconv.ParseFloat(f[4], 64); err != nil {
+		return nil, err
+	}
+	if seg.Samples, err = strconv.Atoi(f[5]); err != nil {
+		return nil, err
+	}
+	for i, v := range f[6:] {
+		// rtl_power writes "nan" for bins it has no power for.
+		if seg.DB[i], err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, err
+		}
+	}
+	return seg, nil
+}
+
+// Sweeper plans the hops of a sweep and measures them one at a time.
+type Sweeper struct {
+	cfg   SweepConfig
+	binHz float64
+	// keep is the number of bins kept from the middle of each hop.
+	keep int
+	// bins is the number of stitched bins over the whole range.
+	bins int
+}
+
+func NewSweeper(cfg SweepConfig) (*Sweeper, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Range.Width == 0 {
+		return nil, ErrEmptySweep
+	}
+	if !isValidRate(cfg.SampleRate) {
+		return nil, ErrRateOutOfRange
+	}
+	binHz := float64(cfg.SampleRate) / float64(cfg.Bins)
+	// Keep an even number so hops are centered on a bin edge.
+	keep := int(float64(cfg.Bins)*(1-cfg.Overlap)) &^ 1
+	if keep < 2 {
+		return nil, ErrBadSampleRate
+	}
+	return &Sweeper{
+		cfg:   cfg,
+		binHz: binHz,
+		keep:  keep,
+		bins:  int(math.Ceil(float64(cfg.Range.Width) / binHz)),
+	}, nil
+}
+
+func (sw *Sweeper) loHz() float64 {
+	return float64(sw.cfg.Range.Center) - float64(sw.cfg.Range.Width)/2
+}
+
+// Hops is the number of times the radio is tuned per sweep.
+func (sw *Sweeper) Hops() int { return (sw.bins + sw.keep - 1) / sw.keep }
+
+// Hop is the band the radio is tuned to for the i-th hop.
+func (sw *Sweeper) Hop(i int) HzBand {
+	c := sw.loHz() + (float64(i*sw.keep)+float64(sw.keep)/2)*sw.binHz
+	return HzBand{Center: uint64(math.Round(c)), Width: uint64(sw.cfg.SampleRate)}
+}
+
+// Measure tunes to the i-th hop and returns its kept bins. The DC bins are
+// interpolated from their neighbors and the last hop is trimmed to the range.
+func (sw *Sweeper) Measure(ctx context.Context, sdr SDR, i int) (*SweepSegment, error) {
+	b := sw.Hop(i)
+	if err := sdr.SetBand(b); err != nil {
+		return nil, err
+	}
+	bins := sw.cfg.Bins
+	ffts := max(1, int(sw.cfg.Dwell.Seconds()*float64(sw.cfg.SampleRate))/bins)
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The first block may still be from before tuning.
+	ch := sdr.Reader().BatchStream64(cctx, bins, ffts+1)
+	if _, ok := <-ch; !ok {
+		return nil, io.EOF
+	}
+	sp := NewSpectralPowerConfig(b.ToMHz(), bins, ffts, sw.cfg.Spectral)
+	if err := sp.Measure(ch); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	avg := sp.Average()
+	// The DC spike leaks over the window's main lobe.
+	dc := sp.cfg.Window.mainLobe()
+	l, r := avg[bins/2-dc-1], avg[bins/2+dc+1]
+	for j := -dc; j <= dc; j++ {
+		avg[bins/2+j] = l + (r-l)*float64(j+dc+1)/float64(2*dc+2)
+	}
+
+	edge := (bins - sw.keep) / 2
+	n := min(sw.keep, sw.bins-i*sw.keep)
+	db := make([]float64, n)
+	copy(db, avg[edge:edge+n])
+	return &SweepSegment{
+		Time:    time.Now(),
+		LoHz:    sw.loHz() + float64(i*sw.keep)*sw.binHz,
+		BinHz:   sw.binHz,
+		Samples: ffts * bins,
+		DB:      db,
+	}, nil
+}
+
+// SweepResult is every hop of a sweep, in frequency order.
+type SweepResult struct {
+	Segments []*SweepSegment
+}
+
+// Spectrum stitches the segments into one spectrum starting at loHz.
+func (sr *SweepResult) Spectrum() (loHz, binHz float64, db []float64) {
+	if len(sr.Segments) == 0 {
+		return 0, 0, nil
+	}
+	for _, s := range sr.Segments {
+		db = append(db, s.DB...)
+	}
+	return sr.Segments[0].LoHz, sr.Segments[0].BinHz, db
+}
+
+// WriteCSV writes the sweep in rtl_power's format, a row per hop.
+func (sr *SweepResult) WriteCSV(w io.Writer) error {
+	for _, s := range sr.Segments {
+		if err := s.WriteCSV(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sweep measures the power spectrum of cfg.Range by stepping the radio
+// across it.
+func Sweep(ctx context.Context, sdr SDR, cfg SweepConfig) (*SweepResult, error) {
+	sw, err := NewSweeper(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sr := &SweepResult{}
+	for i := 0; i < sw.Hops(); i++ {
+		seg, err := sw.Measure(ctx, sdr, i)
+		if err != nil {
+			return nil, err
+		}
+		sr.Segments = append(sr.Segments, seg)
+	}
+	return sr, nil
+}
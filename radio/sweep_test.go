@@ -0,0 +1,121 @@
+package radio
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	// Carriers near the range edges and on a hop boundary.
+	rng := HzBand{Center: 100000000, Width: 5000000}
+	sigs := []uint64{97600000, 99037000, 102400000}
+	scene := SimScene{NoiseDB: -50}
+	for _, hz := range sigs {
+		scene.Signals = append(scene.Signals, SimSignal{Type: SimCarrier, Hz: hz, DB: -20})
+	}
+	sdr := NewSimSDR(ctx, "sim:sweep", scene)
+	defer sdr.Close()
+
+	cfg := SweepConfig{Range: rng, Dwell: 50 * time.Millisecond}
+	sr, err := Sweep(ctx, sdr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sr.Segments) != 4 {
+		t.Fatalf("expected 4 hops, got %d", len(sr.Segments))
+	}
+	loHz, binHz, db := sr.Spectrum()
+	if loHz != 97500000 || binHz != 2000 || len(db) != 2500 {
+		t.Fatalf("got spectrum at %.0f with %.0fHz bins x %d", loHz, binHz, len(db))
+	}
+	noise := db[len(db)/4]
+	for _, hz := range sigs {
+		bin := int((float64(hz) - loHz) / binHz)
+		peak := math.Inf(-1)
+		for i := max(0, bin-2); i <= min(len(db)-1, bin+2); i++ {
+			peak = math.Max(peak, db[i])
+		}
+		if peak-noise < 20 {
+			t.Errorf("carrier at %d only %.1fdB over noise", hz, peak-noise)
+		}
+	}
+	// The hop center at 99.804MHz shouldn't show a DC spike.
+	if dc := db[(99804000-97500000)/2000]; dc-noise > 6 {
+		t.Errorf("DC bin %.1fdB over noise", dc-noise)
+	}
+
+	var buf bytes.Buffer
+	if err := sr.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, next := 0, loHz
+	for sc := bufio.NewScanner(&buf); sc.Scan(); rows++ {
+		f := strings.Split(sc.Text(), ", ")
+		lo, _ := strconv.ParseFloat(f[2], 64)
+		hi, _ := strconv.ParseFloat(f[3], 64)
+		step, _ := strconv.ParseFloat(f[4], 64)
+		if lo != next || step != binHz || int((hi-lo)/step) != len(f)-6 {
+			t.Fatalf("bad row %d: %v", rows, f[:6])
+		}
+		next = hi
+	}
+	if rows != 4 || next != loHz+float64(rng.Width) {
+		t.Fatalf("got %d rows ending at %.0f", rows, next)
+	}
+}
+
+func TestReadSweepCSV(t *testing.T) {
+	// Two rtl_power passes over two hops.
+	csv := `2024-03-01, 10:00:00, 88000000, 89000000, 250000.00, 100, -10.5, -11.0, -12.0, -13.0
+2024-03-01, 10:00:00, 89000000, 90000000, 250000.00, 100, -14.0, -15.0, nan, -17.0
+2024-03-01, 10:00:10, 88000000, 89000000, 250000.00, 100, -20.5, -21.0, -22.0, -23.0
+
+2024-03-01, 10:00:10, 89000000, 90000000, 250000.00, 100, -24.0, -25.0, -26.0, -27.0
+`
+	srs, err := ReadSweepCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srs) != 2 || len(srs[0].Segments) != 2 || len(srs[1].Segments) != 2 {
+		t.Fatalf("expected 2 sweeps of 2 hops, got %+v", srs)
+	}
+	loHz, binHz, db := srs[1].Spectrum()
+	if loHz != 88000000 || binHz != 250000 || len(db) != 8 || db[7] != -27 {
+		t.Fatalf("bad spectrum %.0f %.0f %v", loHz, binHz, db)
+	}
+	if !math.IsNaN(srs[0].Segments[1].DB[2]) {
+		t.Fatal("expected nan bin")
+	}
+	if d := srs[1].Segments[0].Time.Sub(srs[0].Segments[0].Time); d != 10*time.Second {
+		t.Fatalf("sweeps %v apart", d)
+	}
+	if _, err := ReadSweepCSV(strings.NewReader("2024-03-01, 10:00:00, 88000000\n")); err == nil {
+		t.Fatal("expected error on short row")
+	}
+}
+
+func TestSweepDefaults(t *testing.T) {
+	sw, err := NewSweeper(SweepConfig{Range: HzBand{Center: 100000000, Width: 5000000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sw.cfg.Spectral != DefaultSpectralConfig {
+		t.Fatalf("expected default spectral config, got %+v", sw.cfg.Spectral)
+	}
+	// An explicit config keeps its zero overlap.
+	cfg := SpectralConfig{Window: WindowHann}
+	if sw, err = NewSweeper(SweepConfig{Range: HzBand{Center: 100000000, Width: 5000000}, Spectral: cfg}); err != nil {
+		t.Fatal(err)
+	}
+	if sw.cfg.Spectral != cfg {
+		t.Fatalf("expected %+v, got %+v", cfg, sw.cfg.Spectral)
+	}
+}
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Fake SDR program: records its tuning to $3 and streams cu8 samples
+# alternating between 1 and j.
+echo "$1 $2" > "$3"
+while :; do
+	printf '\377\200\200\377\377\200\200\377\377\200\200\377\377\200\200\377'
+done
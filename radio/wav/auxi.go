@@ -0,0 +1,108 @@
+package wav
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Auxi is the "auxi" chunk written by SDR#, HDSDR, and SDRuno with the
+// tuning and time of an I/Q recording.
+type Auxi struct {
+	StartTime time.Time
+	StopTime  time.Time
+	// CenterHz is the tuned frequency at the center of the recording.
+	CenterHz uint32
+	// ADHz is the sampling rate of the A/D converter, if different.
+	ADHz uint32
+	// IFHz is the intermediate frequency, if any.
+	IFHz uint32
+	// BandwidthHz is the usable bandwidth of the recording.
+	BandwidthHz uint32
+}
+
+// systemTime is the Windows SYSTEMTIME struct.
+type systemTime struct {
+	Year, Month, DayOfWeek, Day, Hour, Minute, Second, Milliseconds uint16
+}
+
+// auxiHeader is the HDSDR layout; SDR# writes only up to Unused5.
+type auxiHeader struct {
+	StartTime    systemTime
+	StopTime     systemTime
+	CenterFreq   uint32
+	ADFrequency  uint32
+	IFFrequency  uint32
+	Bandwidth    uint32
+	IQOffset     uint32
+	Unused2      uint32
+	Unused3      uint32
+	Unused4      uint32
+	Unused5      uint32
+	NextFilename [96]byte
+}
+
+const auxiSize = 164
+
+// Bytes up to and including Bandwidth.
+const auxiMinSize = 48
+
+func (a *Auxi) read(r io.Reader, size uint32) error {
+	if size < auxiMinSize {
+		return ErrBadFormat
+	}
+	buf := make([]byte, auxiSize)
+	n := min(size, auxiSize)
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return err
+	}
+	if err := discard(r, int64(size-n)+int64(size%2)); err != nil {
+		return err
+	}
+	var h auxiHeader
+	if _, err := binary.Decode(buf, binary.LittleEndian, &h); err != nil {
+		return err
+	}
+	a.StartTime, a.StopTime = h.StartTime.time(), h.StopTime.time()
+	a.CenterHz, a.ADHz, a.IFHz, a.BandwidthHz = h.CenterFreq, h.ADFrequency, h.IFFrequency, h.Bandwidth
+	return nil
+}
+
+func (a *Auxi) encode() *auxiHeader {
+	return &auxiHeader{
+		StartTime:   newSystemTime(a.StartTime),
+		StopTime:    newSystemTime(a.StopTime),
+		CenterFreq:  a.CenterHz,
+		ADFrequency: a.ADHz,
+		IFFrequency: a.IFHz,
+		Bandwidth:   a.BandwidthHz,
+	}
+}
+
+// SDR software writes UTC times.
+func newSystemTime(t time.Time) systemTime {
+	if t.IsZero() {
+		return systemTime{}
+	}
+	t = t.UTC()
+	return systemTime{
+		Year:         uint16(t.Year()),
+		Month:        uint16(t.Month()),
+		DayOfWeek:    uint16(t.Weekday()),
+		Day:          uint16(t.Day()),
+		Hour:         uint16(t.Hour()),
+		Minute:       uint16(t.Minute()),
+		Second:       uint16(t.Second()),
+		Milliseconds: uint16(t.Nanosecond() / int(time.Millisecond)),
+	}
+}
+
+func (st systemTime) time() time.Time {
+	if st.Year == 0 {
+		return time.Time{}
+	}
+	return time.Date(
+		int(st.Year), time.Month(st.Month), int(st.Day),
+		int(st.Hour), int(st.Minute), int(st.Second),
+		int(st.Milliseconds)*int(time.Millisecond), time.UTC)
+}
@@ -4,23 +4,38 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"math"
+	"time"
 )
 
 var (
 	ErrBadFormat = errors.New("bad format")
 )
 
+const (
+	FormatPCM        = 1
+	FormatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
+// Chunk sizes of 0xffffffff mean the size is unknown (streamed) or, in an
+// RF64 file, is in the ds64 chunk.
+const unknownSize = math.MaxUint32
+
 type riffHeader struct {
-	ChunkId   [4]byte
+	ChunkId   [4]byte /* "RIFF" or "RF64" */
 	ChunkSize uint32
 	Format    [4]byte
 }
 
+type chunkHeader struct {
+	ChunkId   [4]byte
+	ChunkSize uint32
+}
+
 // WaveHeader is wave header struct
 type fmtHeader struct {
-	ChunkId       [4]byte /* "fmt " */
-	ChunkSize     uint32
-	AudioFormat   uint16 /* 1 */
+	AudioFormat   uint16 /* 1 or 3 */
 	NumChannels   uint16
 	SampleRate    uint32
 	ByteRate      uint32
@@ -28,46 +43,128 @@ type fmtHeader struct {
 	BitsPerSample uint16
 }
 
-type metaHeader struct {
-	ChunkId   [4]byte /* "meta" */
-	ChunkSize uint32
-	Key       []uint8
-	Val       []uint8
+// ds64 has the 64-bit sizes of an RF64 file.
+type ds64Header struct {
+	RIFFSize    uint64
+	DataSize    uint64
+	SampleCount uint64
+	TableLength uint32
 }
 
-type dataHeader struct {
-	ChunkId   [4]byte /* "data" */
-	ChunkSize uint32
-}
+const ds64Size = 28
 
 type Reader struct {
 	io.Reader
-	rh riffHeader
-	fh fmtHeader
-	dh dataHeader
+	rh       riffHeader
+	fh       fmtHeader
+	aux      *Auxi
+	dataSize int64
 }
 
 func NewReader(r io.Reader) (*Reader, error) {
-	rr := &Reader{Reader: r}
+	rr := &Reader{Reader: r, dataSize: -1}
 	if err := binary.Read(r, binary.LittleEndian, &rr.rh); err != nil {
 		return nil, err
 	}
-	if string(rr.rh.ChunkId[:]) != "RIFF" || string(rr.rh.Format[:]) != "WAVE" {
+	rf64 := string(rr.rh.ChunkId[:]) == "RF64"
+	if (string(rr.rh.ChunkId[:]) != "RIFF" && !rf64) || string(rr.rh.Format[:]) != "WAVE" {
 		return nil, ErrBadFormat
 	}
+	var ds64 *ds64Header
+	hasFmt := false
+	for {
+		var ch chunkHeader
+		if err := binary.Read(r, binary.LittleEndian, &ch); err != nil {
+			return nil, err
+		}
+		switch string(ch.ChunkId[:]) {
+		case "ds64":
+			ds64 = &ds64Header{}
+			if err := readChunk(r, ch.ChunkSize, ds64); err != nil {
+				return nil, err
+			}
+		case "fmt ":
+			if err := rr.readFmt(r, ch.ChunkSize); err != nil {
+				return nil, err
+			}
+			hasFmt = true
+		case "auxi":
+			rr.aux = &Auxi{}
+			if err := rr.aux.read(r, ch.ChunkSize); err != nil {
+				return nil, err
+			}
+		case "data":
+			if !hasFmt {
+				return nil, ErrBadFormat
+			}
+			if rf64 && ds64 != nil && ch.ChunkSize == unknownSize {
+				rr.dataSize = int64(ds64.DataSize)
+			} else if ch.ChunkSize != unknownSize && ch.ChunkSize != 0 {
+				rr.dataSize = int64(ch.ChunkSize)
+			}
+			if rr.dataSize >= 0 {
+				rr.Reader = io.LimitReader(r, rr.dataSize)
+			}
+			return rr, nil
+		default:
+			if err := skipChunk(r, ch.ChunkSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (rr *Reader) readFmt(r io.Reader, size uint32) error {
+	if size < 16 {
+		return ErrBadFormat
+	}
 	if err := binary.Read(r, binary.LittleEndian, &rr.fh); err != nil {
-		return nil, err
+		return err
 	}
-	if string(rr.fh.ChunkId[:]) != "fmt " || rr.fh.AudioFormat != 1 {
-		return nil, ErrBadFormat
+	ext := make([]byte, size-16)
+	if _, err := io.ReadFull(r, ext); err != nil {
+		return err
 	}
-	if err := binary.Read(r, binary.LittleEndian, &rr.dh); err != nil {
-		return nil, err
+	if err := discard(r, int64(size%2)); err != nil {
+		return err
 	}
-	if string(rr.dh.ChunkId[:]) != "data" {
-		return nil, ErrBadFormat
+	if rr.fh.AudioFormat == formatExtensible {
+		// cbSize, valid bits, channel mask, then the subformat GUID
+		// whose first two bytes are the format code.
+		if len(ext) < 10 {
+			return ErrBadFormat
+		}
+		rr.fh.AudioFormat = binary.LittleEndian.Uint16(ext[8:])
 	}
-	return rr, nil
+	switch {
+	case rr.fh.AudioFormat == FormatPCM && (rr.fh.BitsPerSample == 8 || rr.fh.BitsPerSample == 16):
+	case rr.fh.AudioFormat == FormatFloat && rr.fh.BitsPerSample == 32:
+	default:
+		return ErrBadFormat
+	}
+	return nil
+}
+
+// readChunk reads the start of a chunk into v and skips the rest.
+func readChunk(r io.Reader, size uint32, v any) error {
+	n := uint32(binary.Size(v))
+	if size < n {
+		return ErrBadFormat
+	}
+	if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+		return err
+	}
+	return discard(r, int64(size-n)+int64(size%2))
+}
+
+func skipChunk(r io.Reader, size uint32) error {
+	// Chunks are padded to even lengths.
+	return discard(r, int64(size)+int64(size%2))
+}
+
+func discard(r io.Reader, n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
 }
 
 func (r *Reader) Channels() int {
@@ -78,84 +175,147 @@ func (r *Reader) SampleRate() int {
 	return int(r.fh.SampleRate)
 }
 
+// BitDepth is the number of bits in each channel's samples.
 func (r *Reader) BitDepth() int {
-	return int(r.fh.BitsPerSample / r.fh.NumChannels)
+	return int(r.fh.BitsPerSample)
+}
+
+// Format is FormatPCM or FormatFloat.
+func (r *Reader) Format() int {
+	return int(r.fh.AudioFormat)
 }
 
+// Auxi is the SDR metadata chunk, if any.
+func (r *Reader) Auxi() *Auxi { return r.aux }
+
+// DataSize is the length of the sample data in bytes, or -1 if unknown.
+func (r *Reader) DataSize() int64 { return r.dataSize }
+
 type Writer struct {
 	w io.Writer
 
 	SampleRate    uint32
 	BitsPerSample uint16
 	NumChannels   uint16
+	AudioFormat   uint16
 
-	dataLen uint32
-	// TODO: metadata
+	// Auxi is written as an SDR metadata chunk if set.
+	Auxi *Auxi
+
+	dataLen uint64
 }
 
 func NewWriter(w io.Writer, rate, depth, channels int) (*Writer, error) {
+	return NewWriterFormat(w, rate, depth, channels, FormatPCM, nil)
+}
+
+// NewWriterFormat writes PCM or float samples with optional SDR metadata.
+func NewWriterFormat(w io.Writer, rate, depth, channels, format int, aux *Auxi) (*Writer, error) {
 	if rate == 0 || depth == 0 || channels == 0 {
 		return nil, ErrBadFormat
 	}
+	if format != FormatPCM && (format != FormatFloat || depth != 32) {
+		return nil, ErrBadFormat
+	}
 	ww := &Writer{
 		w:             w,
 		SampleRate:    uint32(rate),
 		BitsPerSample: uint16(depth),
 		NumChannels:   uint16(channels),
+		AudioFormat:   uint16(format),
+		Auxi:          aux,
 	}
-	if err := ww.writeHeader(0); err != nil {
+	if err := ww.writeHeader(false); err != nil {
 		return nil, err
 	}
 	return ww, nil
 }
 
 func (w *Writer) Write(p []byte) (int, error) {
-	w.dataLen += uint32(len(p))
-	return w.w.Write(p)
+	n, err := w.w.Write(p)
+	w.dataLen += uint64(n)
+	return n, err
 }
 
+// Close rewrites the header with the final sizes if the writer can seek.
+// Recordings over 4GB become RF64.
 func (w *Writer) Close() error {
 	if ws, ok := w.w.(io.WriteSeeker); ok {
-		if _, err := ws.Seek(0, 0); err != nil {
+		if w.Auxi != nil && w.Auxi.StopTime.IsZero() {
+			w.Auxi.StopTime = time.Now()
+		}
+		if _, err := ws.Seek(0, io.SeekStart); err != nil {
 			return err
 		}
-		if err := w.writeHeader(w.dataLen); err != nil {
+		if err := w.writeHeader(true); err != nil {
 			return err
 		}
+		_, err := ws.Seek(0, io.SeekEnd)
+		return err
 	}
 	return nil
 }
 
-func (w *Writer) writeHeader(dataLen uint32) error {
-	if dataLen == 0 {
-		dataLen = 1 << 31
+func (w *Writer) writeHeader(final bool) error {
+	auxLen := uint64(0)
+	if w.Auxi != nil {
+		auxLen = 8 + auxiSize
 	}
+	// RIFF size counts everything after its chunk header.
+	hdrLen := 4 + (8 + ds64Size) + (8 + 16) + auxLen + 8
+	riffSize, dataSize := uint64(unknownSize), uint64(unknownSize)
+	if final {
+		riffSize, dataSize = hdrLen+w.dataLen, w.dataLen
+	}
+	rf64 := final && riffSize >= unknownSize
+
 	rh := &riffHeader{
 		ChunkId:   [4]byte{'R', 'I', 'F', 'F'},
-		ChunkSize: dataLen + 32,
+		ChunkSize: uint32(min(riffSize, unknownSize)),
 		Format:    [4]byte{'W', 'A', 'V', 'E'},
 	}
-	if err := binary.Write(w.w, binary.LittleEndian, rh); err != nil {
-		return err
+	// Reserve space for ds64 with a JUNK chunk in case the file grows
+	// past 4GB.
+	ch := &chunkHeader{ChunkId: [4]byte{'J', 'U', 'N', 'K'}, ChunkSize: ds64Size}
+	if rf64 {
+		rh.ChunkId = [4]byte{'R', 'F', '6', '4'}
+		rh.ChunkSize = unknownSize
+		ch.ChunkId = [4]byte{'d', 's', '6', '4'}
+	}
+	ds := &ds64Header{}
+	if rf64 {
+		ds.RIFFSize, ds.DataSize = riffSize, dataSize
+		ds.SampleCount = dataSize / uint64(w.blockAlign())
 	}
 
 	fh := &fmtHeader{
-		ChunkId:       [4]byte{'f', 'm', 't', ' '},
-		ChunkSize:     16,
-		AudioFormat:   1,
+		AudioFormat:   w.AudioFormat,
 		NumChannels:   w.NumChannels,
 		SampleRate:    w.SampleRate,
-		ByteRate:      w.SampleRate * uint32(w.NumChannels) * uint32(w.BitsPerSample) / 8,
-		BlockAlign:    uint16((uint32(w.NumChannels) * uint32(w.BitsPerSample)) / 8),
+		ByteRate:      w.SampleRate * uint32(w.blockAlign()),
+		BlockAlign:    w.blockAlign(),
 		BitsPerSample: w.BitsPerSample,
 	}
-	if err := binary.Write(w.w, binary.LittleEndian, fh); err != nil {
-		return err
+	hdrs := []any{
+		rh,
+		ch, ds,
+		&chunkHeader{ChunkId: [4]byte{'f', 'm', 't', ' '}, ChunkSize: 16}, fh,
 	}
-
-	dh := &dataHeader{
+	if w.Auxi != nil {
+		hdrs = append(hdrs, &chunkHeader{ChunkId: [4]byte{'a', 'u', 'x', 'i'}, ChunkSize: auxiSize}, w.Auxi.encode())
+	}
+	hdrs = append(hdrs, &chunkHeader{
 		ChunkId:   [4]byte{'d', 'a', 't', 'a'},
-		ChunkSize: dataLen,
+		ChunkSize: uint32(min(dataSize, unknownSize)),
+	})
+	for _, h := range hdrs {
+		if err := binary.Write(w.w, binary.LittleEndian, h); err != nil {
+			return err
+		}
 	}
-	return binary.Write(w.w, binary.LittleEndian, dh)
+	return nil
+}
+
+func (w *Writer) blockAlign() uint16 {
+	return uint16((uint32(w.NumChannels) * uint32(w.BitsPerSample)) / 8)
 }
@@ -0,0 +1,76 @@
+package wav
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuxiRoundTrip(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	start := time.Date(2024, 5, 6, 7, 8, 9, 10*int(time.Millisecond), time.UTC)
+	aux := &Auxi{StartTime: start, CenterHz: 100100000, BandwidthHz: 240000}
+	w, err := NewWriterFormat(f, 240000, 32, 2, FormatFloat, aux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 100)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Trailing chunks are not sample data.
+	if _, err := f.Write([]byte("LIST\x00\x00\x00\x00")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format() != FormatFloat || r.BitDepth() != 32 || r.Channels() != 2 || r.SampleRate() != 240000 {
+		t.Fatalf("bad format %+v", r.fh)
+	}
+	got := r.Auxi()
+	if got == nil || got.CenterHz != aux.CenterHz || !got.StartTime.Equal(start) || got.StopTime.IsZero() {
+		t.Fatalf("got auxi %+v, expected %+v", got, aux)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("read %d bytes, expected %d", len(b), len(data))
+	}
+}
+
+func TestRF64(t *testing.T) {
+	var buf bytes.Buffer
+	w := &Writer{w: &buf, SampleRate: 2048000, BitsPerSample: 16, NumChannels: 2, AudioFormat: FormatPCM}
+	w.dataLen = 5 << 30
+	if err := w.writeHeader(true); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf.Bytes()[:4]) != "RF64" {
+		t.Fatalf("expected RF64, got %q", buf.Bytes()[:4])
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.DataSize() != 5<<30 {
+		t.Fatalf("got data size %d, expected %d", r.DataSize(), 5<<30)
+	}
+}
@@ -0,0 +1,67 @@
+package radio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Window is an FFT window function.
+type Window string
+
+const (
+	// WindowRect has the narrowest main lobe but leaks the most.
+	WindowRect Window = "rect"
+	// WindowHann is a general purpose window.
+	WindowHann Window = "hann"
+	// WindowBlackmanHarris has -92dB sidelobes for finding weak signals
+	// next to strong ones.
+	WindowBlackmanHarris Window = "blackman_harris"
+	// WindowFlatTop measures tone amplitudes accurately between bins.
+	WindowFlatTop Window = "flattop"
+)
+
+// Cosine sum coefficients of each window.
+var windowCoeffs = map[Window][]float64{
+	WindowRect:           {1},
+	WindowHann:           {0.5, 0.5},
+	WindowBlackmanHarris: {0.35875, 0.48829, 0.14128, 0.01168},
+	WindowFlatTop:        {0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368},
+}
+
+func ParseWindow(s string) (Window, error) {
+	w := Window(strings.ReplaceAll(strings.ToLower(s), "-", "_"))
+	if w == "" {
+		return WindowHann, nil
+	}
+	if _, ok := windowCoeffs[w]; !ok {
+		return "", fmt.Errorf("unknown window %q", s)
+	}
+	return w, nil
+}
+
+// Coeffs is the periodic window of n samples.
+func (w Window) Coeffs(n int) []float64 {
+	a, ok := windowCoeffs[w]
+	if !ok {
+		a = windowCoeffs[WindowRect]
+	}
+	ret := make([]float64, n)
+	for i := range ret {
+		for k, ak := range a {
+			if k%2 == 1 {
+				ak = -ak
+			}
+			ret[i] += ak * math.Cos(2*math.Pi*float64(k*i)/float64(n))
+		}
+	}
+	return ret
+}
+
+// mainLobe is the half width of the window's main lobe in bins.
+func (w Window) mainLobe() int {
+	if a, ok := windowCoeffs[w]; ok {
+		return len(a) - 1
+	}
+	return 0
+}
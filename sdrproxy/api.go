@@ -5,12 +5,28 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"time"
 
 	"github.com/chzchzchz/nicerx/radio"
 )
 
 var ErrSignalExists = errors.New("signal by that name exists")
 var ErrOutOfRange = errors.New("signal out of range for tuning")
+var ErrRadioNotOpen = errors.New("radio not open")
+
+// SignalErrorTrailer is the HTTP trailer with the error that ended a
+// stream early.
+const SignalErrorTrailer = "Signal-Error"
+
+// ParseSignalError recovers a known error from its text.
+func ParseSignalError(s string) error {
+	for _, err := range []error{radio.ErrDeviceGone, ErrRadioNotOpen, ErrOutOfRange} {
+		if s == err.Error() {
+			return err
+		}
+	}
+	return errors.New(s)
+}
 
 type RxRequest struct {
 	radio.HzBand
@@ -22,6 +38,46 @@ type RxRequest struct {
 	HintTuneHz uint64 `json:"hint_tune_hz"`
 	// HintTuneBw is the samples for the SDR, if possible.
 	HintTuneWidthHz uint64 `json:"hint_width_hz"`
+	// Format is the sample encoding for the stream; defaults to cu8.
+	Format radio.SampleFormat `json:"format,omitempty"`
+	// Backpressure is what happens when the reader falls behind; defaults
+	// to disconnect.
+	Backpressure radio.Backpressure `json:"backpressure,omitempty"`
+	// BufferBatches is the number of one second batches queued for the
+	// reader; defaults to 4.
+	BufferBatches int `json:"buffer_batches,omitempty"`
+	// StallTimeoutMs is how long a disconnect reader may drop samples
+	// before the stream is closed; defaults to 1000.
+	StallTimeoutMs int `json:"stall_timeout_ms,omitempty"`
+}
+
+// StreamConfig is the subscription to the radio for the request.
+func (req *RxRequest) StreamConfig(batch int) radio.StreamConfig {
+	return radio.StreamConfig{
+		Batch:        batch,
+		Backpressure: req.Backpressure,
+		Buffer:       req.BufferBatches,
+		Timeout:      time.Duration(req.StallTimeoutMs) * time.Millisecond,
+	}
+}
+
+// RTLTCPRequest serves a channel as an rtl_tcp server on Bind. If the
+// band is empty, clients tune the whole radio instead.
+type RTLTCPRequest struct {
+	RxRequest
+	Bind string `json:"bind"`
+}
+
+// SDRGain sets the gain stages of an open radio.
+type SDRGain struct {
+	Radio string `json:"radio"`
+	radio.GainConfig
+}
+
+// SDRCalibrate measures the frequency error of an open radio.
+type SDRCalibrate struct {
+	Radio     string             `json:"radio"`
+	Reference radio.CalReference `json:"reference"`
 }
 
 type RxResponse struct {
@@ -32,6 +88,8 @@ type RxResponse struct {
 type RxSignal struct {
 	Request  RxRequest
 	Response RxResponse
+	// Stats counts radio samples delivered to and dropped by the signal.
+	Stats radio.IQStats
 }
 
 func NewRxRequest(rc io.ReadCloser) (*RxRequest, error) {
@@ -46,3 +104,16 @@ func NewRxRequest(rc io.ReadCloser) (*RxRequest, error) {
 	}
 	return &msg, nil
 }
+
+func NewRTLTCPRequest(rc io.ReadCloser) (*RTLTCPRequest, error) {
+	b, err := ioutil.ReadAll(rc)
+	defer rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	var msg RTLTCPRequest
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
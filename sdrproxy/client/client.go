@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 
 	"github.com/chzchzchz/nicerx/radio"
@@ -50,8 +52,12 @@ func (c *Client) OpenIQReader(ctx context.Context, rxreq sdrproxy.RxRequest) (*r
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf(http.StatusText(resp.StatusCode))
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		if msg := strings.TrimSpace(string(b)); msg != "" {
+			return nil, sdrproxy.ParseSignalError(msg)
+		}
+		return nil, fmt.Errorf("%s", http.StatusText(resp.StatusCode))
 	}
 
 	c.wg.Add(1)
@@ -61,7 +67,30 @@ func (c *Client) OpenIQReader(ctx context.Context, rxreq sdrproxy.RxRequest) (*r
 		resp.Body.Close()
 	}()
 
-	return radio.NewIQReader(resp.Body), nil
+	format := rxreq.Format
+	var rxresp sdrproxy.RxResponse
+	if err := json.Unmarshal([]byte(resp.Header.Get("Signal")), &rxresp); err == nil && rxresp.Format.Format != "" {
+		format = rxresp.Format.Format
+	}
+	if format == "" {
+		format = radio.FormatCU8
+	}
+	return radio.NewIQReaderFormat(&signalReader{resp}, format), nil
+}
+
+// signalReader reports the error that ended a stream instead of EOF.
+type signalReader struct {
+	resp *http.Response
+}
+
+func (r *signalReader) Read(p []byte) (int, error) {
+	n, err := r.resp.Body.Read(p)
+	if err == io.EOF {
+		if msg := r.resp.Trailer.Get(sdrproxy.SignalErrorTrailer); msg != "" {
+			return n, sdrproxy.ParseSignalError(msg)
+		}
+	}
+	return n, err
 }
 
 func (c *Client) Signals(ctx context.Context) (msg []sdrproxy.RxSignal, err error) {
@@ -85,6 +114,88 @@ func (c *Client) Signals(ctx context.Context) (msg []sdrproxy.RxSignal, err erro
 	return msg, nil
 }
 
+func (c *Client) SetGain(ctx context.Context, g sdrproxy.SDRGain) error {
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	u := c.Endpoint.String() + "/api/sdr/gain"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+// Calibrate measures and records the frequency error of an open radio.
+func (c *Client) Calibrate(ctx context.Context, cal sdrproxy.SDRCalibrate) (ret radio.Calibration, err error) {
+	b, err := json.Marshal(cal)
+	if err != nil {
+		return ret, err
+	}
+	u := c.Endpoint.String() + "/api/sdr/calibrate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewBuffer(b))
+	if err != nil {
+		return ret, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ret, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ret, fmt.Errorf("%s", http.StatusText(resp.StatusCode))
+	}
+	if b, err = ioutil.ReadAll(resp.Body); err != nil {
+		return ret, err
+	}
+	err = json.Unmarshal(b, &ret)
+	return ret, err
+}
+
+// Events streams radios being plugged in and unplugged until ctx is done.
+func (c *Client) Events(ctx context.Context) (<-chan radio.DeviceEvent, error) {
+	u := c.Endpoint.String() + "/api/sdr/events"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s", http.StatusText(resp.StatusCode))
+	}
+	evc := make(chan radio.DeviceEvent)
+	go func() {
+		defer close(evc)
+		defer resp.Body.Close()
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var ev radio.DeviceEvent
+			if err := dec.Decode(&ev); err != nil {
+				return
+			}
+			select {
+			case evc <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return evc, nil
+}
+
 func (c *Client) Close() error {
 	c.cancel()
 	c.wg.Wait()
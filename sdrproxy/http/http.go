@@ -12,6 +12,8 @@ func ServeHttp(s *server.Server, serv string) error {
 	mux.Handle("/api/rx/", http.StripPrefix("/api/rx", newRXHandler(s)))
 	// Add/remove/list sdr status.
 	mux.Handle("/api/sdr/", http.StripPrefix("/api/sdr", newSDRHandler(s)))
+	// Add/remove/list rtl_tcp servers for rx streams.
+	mux.Handle("/api/rtltcp/", http.StripPrefix("/api/rtltcp", newRTLTCPHandler(s)))
 	// mux.Handle("/", newIndexHandler(s))
 	return http.ListenAndServe(serv, mux)
 }
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/chzchzchz/nicerx/sdrproxy"
+	"github.com/chzchzchz/nicerx/sdrproxy/rtltcp"
+	"github.com/chzchzchz/nicerx/sdrproxy/server"
+)
+
+type rtltcpHandler struct {
+	serv *server.Server
+
+	// listeners maps bind addresses to rtl_tcp servers.
+	listeners map[string]*rtltcp.Listener
+	mu        sync.Mutex
+}
+
+func newRTLTCPHandler(s *server.Server) http.Handler {
+	return &rtltcpHandler{serv: s, listeners: make(map[string]*rtltcp.Listener)}
+}
+
+func (h *rtltcpHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	req, err := sdrproxy.NewRTLTCPRequest(r.Body)
+	if err != nil {
+		return err
+	}
+	l, err := rtltcp.Listen(h.serv, *req)
+	if err != nil {
+		return err
+	}
+	bind := l.Addr().String()
+	h.mu.Lock()
+	h.listeners[bind] = l
+	h.mu.Unlock()
+	go func() {
+		err := l.Serve()
+		log.Printf("rtl_tcp server %s stopped: %v", bind, err)
+		h.mu.Lock()
+		delete(h.listeners, bind)
+		h.mu.Unlock()
+	}()
+	return h.writeJSON(w, l.Request())
+}
+
+func (h *rtltcpHandler) handleGet(w http.ResponseWriter, r *http.Request) error {
+	h.mu.Lock()
+	reqs := make([]sdrproxy.RTLTCPRequest, 0, len(h.listeners))
+	for _, l := range h.listeners {
+		reqs = append(reqs, l.Request())
+	}
+	h.mu.Unlock()
+	return h.writeJSON(w, reqs)
+}
+
+func (h *rtltcpHandler) handleDelete(w http.ResponseWriter, r *http.Request) error {
+	h.mu.Lock()
+	l := h.listeners[r.URL.Path[1:]]
+	h.mu.Unlock()
+	if l == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	return l.Close()
+}
+
+func (h *rtltcpHandler) writeJSON(w http.ResponseWriter, v interface{}) error {
+	respBytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(respBytes)
+	return err
+}
+
+func (h *rtltcpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		err = h.handlePost(w, r)
+	case http.MethodGet:
+		err = h.handleGet(w, r)
+	case http.MethodDelete:
+		err = h.handleDelete(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
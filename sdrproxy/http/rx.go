@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -36,12 +37,15 @@ func (rxh *rxHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Signal", string(respBytes))
 	w.Header().Set("Content-Type", "application/octet-stream")
 
+	format := s.Response().Format.Format
 	bw := req.HzBand.Width
-	fname := fmt.Sprintf("%v:[%v,%v].iq8", req.HzBand.Center, req.HzBand.Center-bw/2, req.HzBand.Center+bw/2)
+	fname := fmt.Sprintf("%v:[%v,%v]%s", req.HzBand.Center, req.HzBand.Center-bw/2, req.HzBand.Center+bw/2, format.Ext())
 	w.Header().Set("Content-Disposition", `inline; filename="`+fname+`"`)
+	// Errors after the stream starts are reported in the trailer.
+	w.Header().Set("Trailer", sdrproxy.SignalErrorTrailer)
 
 	// Stream out data.
-	iqw := radio.NewIQWriter(w)
+	iqw := radio.NewIQWriterFormat(w, format)
 	log.Printf("[%s] opened stream %+v", r.RemoteAddr, s.Response())
 	for sig := range s.Chan() {
 		if err = iqw.Write64(sig); err != nil {
@@ -49,6 +53,10 @@ func (rxh *rxHandler) handlePost(w http.ResponseWriter, r *http.Request) error {
 			break
 		}
 	}
+	if err := s.Err(); err != nil {
+		log.Printf("[%s] stream %s failed: %v", r.RemoteAddr, req.Name, err)
+		w.Header().Set(sdrproxy.SignalErrorTrailer, err.Error())
+	}
 	log.Printf("[%s] closing %s", r.RemoteAddr, req.Name)
 	return nil
 }
@@ -76,8 +84,12 @@ func (rxh *rxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 	if err != nil {
-		log.Printf("[%s] failed %s %s", r.RemoteAddr, err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("[%s] failed %s", r.RemoteAddr, err.Error())
+		code := http.StatusInternalServerError
+		if errors.Is(err, radio.ErrDeviceGone) {
+			code = http.StatusGone
+		}
+		http.Error(w, err.Error(), code)
 	}
 
 }
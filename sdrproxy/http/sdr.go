@@ -2,9 +2,12 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/sdrproxy"
 	"github.com/chzchzchz/nicerx/sdrproxy/server"
 )
 
@@ -12,10 +15,18 @@ type sdrHandler struct {
 	serv *server.Server
 }
 
-func newSDRHandler(s *server.Server) http.Handler { return &sdrHandler{s} }
+func newSDRHandler(s *server.Server) http.Handler {
+	sh := &sdrHandler{s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gain", sh.handleGain)
+	mux.HandleFunc("/calibrate", sh.handleCalibrate)
+	mux.HandleFunc("/events", sh.handleEvents)
+	mux.HandleFunc("/", sh.handleIndex)
+	return mux
+}
 
 func (sh *sdrHandler) handleGet(w http.ResponseWriter, r *http.Request) error {
-	sdrs, err := radio.SDRList(r.Context())
+	sdrs, err := sh.serv.SDRs(r.Context())
 	if err != nil {
 		return err
 	}
@@ -28,7 +39,7 @@ func (sh *sdrHandler) handleGet(w http.ResponseWriter, r *http.Request) error {
 	return err
 }
 
-func (sh *sdrHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (sh *sdrHandler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	var err error
 	switch r.Method {
 	case http.MethodGet:
@@ -40,3 +51,89 @@ func (sh *sdrHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+func (sh *sdrHandler) handleGain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var msg sdrproxy.SDRGain
+	if err := json.Unmarshal(b, &msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := sh.serv.SetGain(msg.Radio, msg.GainConfig); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, sdrproxy.ErrRadioNotOpen) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+	}
+}
+
+func (sh *sdrHandler) handleCalibrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var msg sdrproxy.SDRCalibrate
+	if err := json.Unmarshal(b, &msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg.Reference.Type == "" {
+		msg.Reference = radio.DefaultCalReference
+	}
+	c, err := sh.serv.Calibrate(msg.Radio, msg.Reference)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, sdrproxy.ErrRadioNotOpen) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	respBytes, err := json.Marshal(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respBytes)
+}
+
+// handleEvents streams radios being plugged in and unplugged as
+// newline-delimited JSON.
+func (sh *sdrHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	f, _ := w.(http.Flusher)
+	if f != nil {
+		f.Flush()
+	}
+	enc := json.NewEncoder(w)
+	for ev := range sh.serv.Events(r.Context()) {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+		if f != nil {
+			f.Flush()
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/sdrproxy"
+	"github.com/chzchzchz/nicerx/sdrproxy/client"
+	"github.com/chzchzchz/nicerx/sdrproxy/server"
+)
+
+// TestSetGain sets the gain of an open simulated radio through the API.
+func TestSetGain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	s := server.NewServer()
+	defer s.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/api/sdr/", http.StripPrefix("/api/sdr", newSDRHandler(s)))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.New(*u)
+
+	g := radio.GainConfig{TenthDB: 297, BiasTee: true}
+	if err := c.SetGain(ctx, sdrproxy.SDRGain{Radio: "sim:", GainConfig: g}); err == nil {
+		t.Fatal("expected error setting gain of unopened radio")
+	}
+
+	req := sdrproxy.RxRequest{HzBand: radio.HzBand{Center: 100100000, Width: 240000}, Name: "gain", Radio: "sim:"}
+	sig, err := s.OpenSignal(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	if err := c.SetGain(ctx, sdrproxy.SDRGain{Radio: "sim:", GainConfig: g}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Gain("sim:"); got != g {
+		t.Fatalf("expected gain %+v, got %+v", g, got)
+	}
+}
@@ -0,0 +1,256 @@
+package rtltcp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/sdrproxy"
+	"github.com/chzchzchz/nicerx/sdrproxy/server"
+)
+
+// Number of gain steps on an R820T.
+const r820tGains = 29
+
+// How long to wait for more tuning commands before retuning.
+const retuneDelay = 50 * time.Millisecond
+
+// Listener serves an sdrproxy channel to rtl_tcp clients. Like rtl_tcp, it
+// serves one client at a time and keeps the tuning between connections.
+type Listener struct {
+	serv *server.Server
+	l    net.Listener
+
+	// whole tunes the radio instead of a channel on the radio.
+	whole bool
+
+	req     sdrproxy.RTLTCPRequest
+	retunec chan struct{}
+	// gain is the client's gain settings, applied to whole radios.
+	gain *radio.GainConfig
+	mu   sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func Listen(s *server.Server, req sdrproxy.RTLTCPRequest) (*Listener, error) {
+	// rtl_tcp clients only understand cu8.
+	if req.Format != "" && req.Format != radio.FormatCU8 {
+		return nil, fmt.Errorf("rtl_tcp streams are %s, not %s", radio.FormatCU8, req.Format)
+	}
+	l, err := net.Listen("tcp", req.Bind)
+	if err != nil {
+		return nil, err
+	}
+	req.Bind = l.Addr().String()
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("%s-rtltcp-%s", req.Radio, req.Bind)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Listener{
+		serv:    s,
+		l:       l,
+		whole:   req.HzBand == radio.HzBand{},
+		req:     req,
+		retunec: make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+func (l *Listener) Request() sdrproxy.RTLTCPRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.req
+}
+
+func (l *Listener) Addr() net.Addr { return l.l.Addr() }
+
+func (l *Listener) Serve() error {
+	for {
+		c, err := l.l.Accept()
+		if err != nil {
+			return err
+		}
+		log.Printf("[%s] rtl_tcp client connected to %s", c.RemoteAddr(), l.Addr())
+		l.wg.Add(1)
+		l.serveConn(c)
+		l.wg.Done()
+		log.Printf("[%s] rtl_tcp client disconnected", c.RemoteAddr())
+	}
+}
+
+func (l *Listener) Close() error {
+	err := l.l.Close()
+	l.cancel()
+	l.wg.Wait()
+	return err
+}
+
+func (l *Listener) serveConn(c net.Conn) {
+	defer c.Close()
+	ctx, cancel := context.WithCancel(l.ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+	if err := binary.Write(c, binary.BigEndian, radio.NewDongleInfo(r820tGains)); err != nil {
+		return
+	}
+	go func() {
+		defer cancel()
+		l.readCommands(c)
+	}()
+	l.stream(ctx, c)
+}
+
+func (l *Listener) readCommands(c net.Conn) {
+	for {
+		cmd, err := radio.ReadCommand(c)
+		if err != nil {
+			return
+		}
+		switch cmd.Command {
+		case radio.CmdCenterFreq:
+			l.retune(func(b *radio.HzBand) { b.Center = uint64(cmd.Parameter) })
+		case radio.CmdSampleRate:
+			l.retune(func(b *radio.HzBand) { b.Width = uint64(cmd.Parameter) })
+		case radio.CmdTunerGainMode:
+			l.setGain(func(g *radio.GainConfig) { g.TunerAGC = cmd.Parameter == 0 })
+		case radio.CmdTunerGain:
+			l.setGain(func(g *radio.GainConfig) { g.TenthDB = cmd.Parameter })
+		case radio.CmdAGCMode:
+			l.setGain(func(g *radio.GainConfig) { g.AGC = cmd.Parameter != 0 })
+		case radio.CmdBiasTee:
+			l.setGain(func(g *radio.GainConfig) { g.BiasTee = cmd.Parameter != 0 })
+		default:
+			log.Printf("[%s] ignoring rtl_tcp command %d(%d)", c.RemoteAddr(), cmd.Command, cmd.Parameter)
+		}
+	}
+}
+
+func (l *Listener) retune(f func(*radio.HzBand)) {
+	l.mu.Lock()
+	f(&l.req.HzBand)
+	if l.whole {
+		l.req.HintTuneHz, l.req.HintTuneWidthHz = l.req.Center, l.req.Width
+	}
+	l.mu.Unlock()
+	select {
+	case l.retunec <- struct{}{}:
+	default:
+	}
+}
+
+// setGain updates the client's gain settings. Channels share the radio with
+// other clients, so only whole-radio listeners change the radio's gain. The
+// radio may be busy retuning, so it is set without holding the lock.
+func (l *Listener) setGain(f func(*radio.GainConfig)) {
+	if !l.whole {
+		return
+	}
+	l.mu.Lock()
+	id, gain := l.req.Radio, l.gain
+	l.mu.Unlock()
+	var g radio.GainConfig
+	if gain != nil {
+		g = *gain
+	} else {
+		g = l.serv.Gain(id)
+	}
+	f(&g)
+	l.mu.Lock()
+	l.gain = &g
+	l.mu.Unlock()
+	if err := l.serv.SetGain(id, g); err != nil && !errors.Is(err, sdrproxy.ErrRadioNotOpen) {
+		log.Printf("[%s] failed to set gain: %v", id, err)
+	}
+}
+
+// applyGain sets the client's gain on a newly opened radio.
+func (l *Listener) applyGain() {
+	l.mu.Lock()
+	id, gain := l.req.Radio, l.gain
+	l.mu.Unlock()
+	if gain == nil {
+		return
+	}
+	if err := l.serv.SetGain(id, *gain); err != nil {
+		log.Printf("[%s] failed to set gain: %v", id, err)
+	}
+}
+
+func (l *Listener) rxRequest() sdrproxy.RxRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.req.RxRequest
+}
+
+// stream opens the channel and writes its samples to the client, reopening
+// it whenever the client retunes.
+func (l *Listener) stream(ctx context.Context, c net.Conn) {
+	iqw := radio.NewIQWriter(c)
+	for ctx.Err() == nil {
+		// Let the client finish sending its setup commands.
+		select {
+		case <-time.After(retuneDelay):
+		case <-l.retunec:
+			continue
+		case <-ctx.Done():
+			return
+		}
+		req := l.rxRequest()
+		if req.Center == 0 || req.Width == 0 {
+			select {
+			case <-l.retunec:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		sig, err := l.serv.OpenSignal(ctx, req)
+		if err != nil {
+			log.Printf("[%s] failed to tune %+v: %v", c.RemoteAddr(), req.HzBand, err)
+			select {
+			case <-l.retunec:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		l.applyGain()
+		log.Printf("[%s] streaming %+v", c.RemoteAddr(), sig.Response())
+		err = pump(ctx, iqw, sig.Chan(), l.retunec)
+		sig.Close()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pump copies samples until the client retunes, disconnects, or the
+// channel ends.
+func pump(ctx context.Context, iqw *radio.IQWriter, sigc server.SignalChannel, retunec <-chan struct{}) error {
+	for {
+		select {
+		case samps, ok := <-sigc:
+			if !ok {
+				return nil
+			}
+			if err := iqw.Write64(samps); err != nil {
+				return err
+			}
+		case <-retunec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
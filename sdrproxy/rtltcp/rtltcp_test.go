@@ -0,0 +1,194 @@
+package rtltcp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/sdrproxy"
+	"github.com/chzchzchz/nicerx/sdrproxy/server"
+)
+
+// TestRemoteSim tunes a simulated radio through the rtl_tcp front-end.
+func TestRemoteSim(t *testing.T) {
+	s := server.NewServer()
+	defer s.Close()
+	req := sdrproxy.RTLTCPRequest{
+		RxRequest: sdrproxy.RxRequest{Radio: "sim:"},
+		Bind:      "127.0.0.1:0",
+	}
+	l, err := Listen(s, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go l.Serve()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	sdr, err := radio.NewSDRWithSerial(ctx, "tcp://"+l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	// Skip calibration.
+	if err := sdr.SetFreqCorrection(0); err != nil {
+		t.Fatal(err)
+	}
+	band := radio.HzBand{Center: 100100000, Width: 240000}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	samples := 0
+	for samps := range sdr.Reader().BatchStream64(ctx, 24000, 10) {
+		samples += len(samps)
+	}
+	if samples != 240000 {
+		t.Fatalf("expected 240000 samples, got %d", samples)
+	}
+	if got := l.Request().HzBand; got != band {
+		t.Fatalf("expected tuning %+v, got %+v", band, got)
+	}
+	sigs := s.Signals()
+	if len(sigs) != 1 || sigs[0].Response.Radio.HzBand() != band {
+		t.Fatalf("expected sim radio tuned to %+v, got %+v", band, sigs)
+	}
+}
+
+// dropProxy forwards connections to an rtl_tcp server so they can be cut.
+type dropProxy struct {
+	l  net.Listener
+	to string
+
+	mu    sync.Mutex
+	down  bool
+	conns []net.Conn
+}
+
+func newDropProxy(t *testing.T, to string) *dropProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &dropProxy{l: l, to: to}
+	go p.serve()
+	return p
+}
+
+func (p *dropProxy) serve() {
+	for {
+		c, err := p.l.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		if p.down {
+			p.mu.Unlock()
+			c.Close()
+			continue
+		}
+		s, err := net.Dial("tcp", p.to)
+		if err != nil {
+			p.mu.Unlock()
+			c.Close()
+			continue
+		}
+		p.conns = append(p.conns, c, s)
+		p.mu.Unlock()
+		go pipe(s, c)
+		go pipe(c, s)
+	}
+}
+
+// pipe copies until either side closes, then closes both.
+func pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	dst.Close()
+	src.Close()
+}
+
+// drop closes all open connections; new ones are refused while down.
+func (p *dropProxy) drop(down bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = down
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.conns = nil
+}
+
+func (p *dropProxy) Close() {
+	p.l.Close()
+	p.drop(true)
+}
+
+// TestRemoteRedial drops a remote radio's connection and checks the stream
+// resumes with the same tuning.
+func TestRemoteRedial(t *testing.T) {
+	s := server.NewServer()
+	defer s.Close()
+	req := sdrproxy.RTLTCPRequest{
+		RxRequest: sdrproxy.RxRequest{Radio: "sim:"},
+		Bind:      "127.0.0.1:0",
+	}
+	l, err := Listen(s, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go l.Serve()
+	p := newDropProxy(t, l.Addr().String())
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 30*time.Second)
+	defer cancel()
+	sdr, err := radio.NewSDRWithSerial(ctx, "tcp://"+p.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sdr.Close()
+	if err := sdr.SetFreqCorrection(0); err != nil {
+		t.Fatal(err)
+	}
+	band := radio.HzBand{Center: 100100000, Width: 240000}
+	if err := sdr.SetBand(band); err != nil {
+		t.Fatal(err)
+	}
+	sampc := sdr.Reader().BatchStream64(ctx, 24000, 20)
+	for i := 0; i < 5; i++ {
+		<-sampc
+	}
+
+	// Retune the server away so the redial has something to restore.
+	l.retune(func(b *radio.HzBand) { b.Center = 101100000 })
+	p.drop(true)
+	time.Sleep(200 * time.Millisecond)
+	// The radio stays usable while the connection is redialed.
+	donec := make(chan struct{})
+	go func() {
+		sdr.Reader()
+		close(donec)
+	}()
+	select {
+	case <-donec:
+	case <-time.After(time.Second):
+		t.Fatal("reader blocked by redial")
+	}
+	p.drop(false)
+
+	samples := 5 * 24000
+	for samps := range sampc {
+		samples += len(samps)
+	}
+	if samples != 20*24000 {
+		t.Fatalf("expected %d samples, got %d", 20*24000, samples)
+	}
+	if got := l.Request().HzBand; got != band {
+		t.Fatalf("expected tuning %+v restored, got %+v", band, got)
+	}
+}
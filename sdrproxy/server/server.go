@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"io"
+	"log"
 	"sync"
 
 	"github.com/chzchzchz/nicerx/radio"
@@ -12,6 +13,8 @@ import (
 type serverSDR struct {
 	radio.SDR
 	readyc <-chan struct{}
+	// retunes counts the times the server moved the radio off its band.
+	retunes int
 }
 
 type Server struct {
@@ -24,17 +27,98 @@ type Server struct {
 	// muxers holds all SDR muxer readers
 	// TODO TODO TODO
 
+	// cal holds calibrations applied to radios when opened.
+	cal *radio.CalibrationTable
+
+	// drift holds the drift monitor of every radio opened, if monitoring.
+	drift    map[string]*radio.DriftMonitor
+	driftCfg *radio.DriftConfig
+
+	// profiles configures radios as they open.
+	profiles *radio.ProfileTable
+
+	// disc finds the radios on the system and tracks which are open.
+	disc *radio.Discovery
+
 	rwmu sync.RWMutex
 }
 
 func NewServer() *Server {
 	return &Server{
-		sdrs:    make(map[string]*serverSDR),
-		signals: make(map[string]*Signal),
+		sdrs:     make(map[string]*serverSDR),
+		signals:  make(map[string]*Signal),
+		profiles: radio.NewProfileTable(),
+		disc:     radio.DefaultDiscovery,
 	}
 }
 
+// SetProfileTable configures radios from the table as they open. Radios
+// may be requested by their profile's name.
+func (s *Server) SetProfileTable(t *radio.ProfileTable) { s.profiles = t }
+
+// SetDiscovery replaces the system's radio discovery.
+func (s *Server) SetDiscovery(d *radio.Discovery) { s.disc = d }
+
+// Events streams radios being plugged in and unplugged until ctx is done.
+func (s *Server) Events(ctx context.Context) <-chan radio.DeviceEvent {
+	return s.disc.Subscribe(ctx)
+}
+
+// SetCalibrationTable applies calibrations from the table to radios as
+// they open and records new calibrations into it.
+func (s *Server) SetCalibrationTable(t *radio.CalibrationTable) { s.cal = t }
+
+// SetDriftConfig re-measures the frequency error of radios when they are
+// idle: as they open, before serving any signal, and once their last signal
+// closes, before closing them.
+func (s *Server) SetDriftConfig(cfg radio.DriftConfig) {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	s.driftCfg, s.drift = &cfg, make(map[string]*radio.DriftMonitor)
+}
+
+// idleDrift is the drift monitor of a radio that is open without signals
+// and due for a measurement, if any.
+func (s *Server) idleDrift(id string) *radio.DriftMonitor {
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+	sdr, dm := s.sdrs[id], s.drift[id]
+	if sdr == nil || sdr.SDR == nil || dm == nil || !dm.Due() || s.hasSignals(id) || s.profiles.Get(id).PPM != nil {
+		return nil
+	}
+	select {
+	case <-sdr.readyc:
+		return dm
+	default:
+		// Still opening or retuning.
+		return nil
+	}
+}
+
+func (s *Server) driftMonitor(id string) *radio.DriftMonitor {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	if s.driftCfg == nil {
+		return nil
+	}
+	dm := s.drift[id]
+	if dm == nil {
+		dm = radio.NewDriftMonitor(*s.driftCfg, s.cal)
+		s.drift[id] = dm
+	}
+	return dm
+}
+
 func (s *Server) OpenSignal(ctx context.Context, req sdrproxy.RxRequest) (sig *Signal, err error) {
+	format, err := radio.ParseSampleFormat(string(req.Format))
+	if err != nil {
+		return nil, err
+	}
+	req.Format = format
+	req.Radio = s.profiles.Resolve(req.Radio)
+	if req.Backpressure, err = radio.ParseBackpressure(string(req.Backpressure)); err != nil {
+		return nil, err
+	}
 	cctx, cancel := context.WithCancel(ctx)
 	s.rwmu.Lock()
 	sig, ok := s.signals[req.Name]
@@ -47,7 +131,7 @@ func (s *Server) OpenSignal(ctx context.Context, req sdrproxy.RxRequest) (sig *S
 	s.rwmu.Unlock()
 
 	if ok {
-		if req.HzBand != sig.req.HzBand || req.Radio != sig.req.Radio {
+		if req.HzBand != sig.req.HzBand || req.Radio != sig.req.Radio || req.Format != sig.req.Format || req.Backpressure != sig.req.Backpressure {
 			return nil, sdrproxy.ErrSignalExists
 		}
 		select {
@@ -64,14 +148,15 @@ func (s *Server) OpenSignal(ctx context.Context, req sdrproxy.RxRequest) (sig *S
 		return nil, err
 	}
 
-	if sig.sigc, err = newSignalChannel(cctx, req.HzBand, r); err != nil {
+	if sig.sigc, err = sig.newSignalChannel(cctx, req, r); err != nil {
 		s.removeSignal(req.Name)
 		return nil, err
 	}
 	dataFormat := radio.SDRFormat{
-		BitDepth:   8, //info.BitDepth,
+		BitDepth:   format.BitDepth(),
 		CenterHz:   req.HzBand.Center,
 		SampleRate: uint32(req.HzBand.Width),
+		Format:     format,
 	}
 	sig.resp = sdrproxy.RxResponse{Format: dataFormat, Radio: sdr.Info()}
 	return sig, nil
@@ -95,12 +180,13 @@ func (s *Server) openSDR(ctx context.Context, req sdrproxy.RxRequest) (radio.SDR
 		defer close(readyc)
 		s.sdrs[req.Radio] = curSDR
 	}
+	readyc := curSDR.readyc
 	s.rwmu.Unlock()
 
 	if ok {
 		// Wait for SDR to be ready.
 		select {
-		case <-curSDR.readyc:
+		case <-readyc:
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		}
@@ -110,12 +196,27 @@ func (s *Server) openSDR(ctx context.Context, req sdrproxy.RxRequest) (radio.SDR
 		return curSDR.SDR, nil
 	}
 
-	sdr, err := radio.NewSDRWithSerial(ctx, req.Radio)
+	// The radio outlives the signal opening it; closeSDR closes it.
+	sdr, err := s.profiles.Open(context.WithoutCancel(ctx), req.Radio)
 	if err != nil {
 		s.closeSDR(req.Radio)
 		return nil, err
 	}
 	curSDR.SDR = sdr
+	s.disc.SetInUse(req.Radio, true)
+	prof := s.profiles.Get(req.Radio)
+	// A pinned correction overrides calibrations.
+	if s.cal != nil && prof.PPM == nil {
+		if err := s.cal.Apply(sdr); err != nil {
+			s.closeSDR(req.Radio)
+			return nil, err
+		}
+	}
+	if dm := s.driftMonitor(req.Radio); dm != nil && prof.PPM == nil && dm.Due() {
+		if _, err := dm.Measure(sdr); err != nil {
+			log.Printf("measuring drift of %s: %v", req.Radio, err)
+		}
+	}
 
 	// Adjust band based on hints to cover wider range without retuning.
 	sdrBand := req.HzBand
@@ -123,11 +224,19 @@ func (s *Server) openSDR(ctx context.Context, req sdrproxy.RxRequest) (radio.SDR
 		w := uint64(2048000)
 		if req.HintTuneWidthHz != 0 {
 			w = req.HintTuneWidthHz
+		} else if prof.SampleRate != 0 {
+			w = uint64(prof.SampleRate)
 		}
 		sdrBand = radio.HzBand{Center: req.HintTuneHz, Width: w}
+	} else if uint64(prof.SampleRate) >= sdrBand.Width {
+		sdrBand.Width = uint64(prof.SampleRate)
 	} else {
 		sdrBand.Width = uint64(getSampleRate(uint32(sdrBand.Width)))
 	}
+	// Recordings only play back the band they recorded.
+	if info := sdr.Info(); info.MinSampleRate == info.MaxSampleRate {
+		sdrBand = radio.HzBand{Center: info.CenterHz, Width: uint64(info.MaxSampleRate)}
+	}
 
 	if err := sdr.SetBand(sdrBand); err != nil {
 		s.closeSDR(req.Radio)
@@ -140,8 +249,11 @@ func (s *Server) openSDR(ctx context.Context, req sdrproxy.RxRequest) (radio.SDR
 func (s *Server) Close() {
 	s.rwmu.Lock()
 	defer s.rwmu.Unlock()
-	for _, sdr := range s.sdrs {
-		sdr.Close()
+	for id, sdr := range s.sdrs {
+		if sdr.SDR != nil {
+			sdr.Close()
+			s.disc.SetInUse(id, false)
+		}
 	}
 	s.sdrs = make(map[string]*serverSDR)
 }
@@ -156,28 +268,169 @@ func (s *Server) removeSignal(name string) {
 	}
 }
 
-func (s *Server) closeSDR(name string) {
-	s.rwmu.Lock()
-	defer s.rwmu.Unlock()
+// hasSignals is true if any signal references the SDR.
+func (s *Server) hasSignals(name string) bool {
 	for _, sig := range s.signals {
 		if sig.req.Radio == name {
-			return
+			return true
 		}
 	}
+	return false
+}
+
+// closeSDR closes a radio once no signals reference it. A radio due for a
+// drift measurement is measured first; signals opening meanwhile wait for
+// the measurement and keep the radio open.
+func (s *Server) closeSDR(name string) {
+	if dm := s.idleDrift(name); dm != nil {
+		err := s.retune(name, func(sdr radio.SDR) error {
+			_, err := dm.Measure(sdr)
+			return err
+		})
+		if err != nil {
+			log.Printf("measuring drift of %s: %v", name, err)
+		}
+	}
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+	if s.hasSignals(name) {
+		return
+	}
 	// No signals reference SDR; may close.
 	if sdr := s.sdrs[name]; sdr != nil {
 		if sdr.SDR != nil {
 			sdr.Close()
+			s.disc.SetInUse(name, false)
 		}
 		delete(s.sdrs, name)
 	}
 }
 
+// SDRs lists the radios on the system, reporting the current settings of
+// open radios.
+func (s *Server) SDRs(ctx context.Context) ([]radio.SDRHWInfo, error) {
+	infos, err := s.disc.SDRList()
+	if err != nil {
+		return nil, err
+	}
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+	s.profiles.ApplyInfo(infos)
+	for id, sdr := range s.sdrs {
+		if sdr.SDR == nil {
+			continue
+		}
+		info, found := sdr.Info(), false
+		info.InUse = true
+		for i := range infos {
+			if infos[i].Id == id {
+				infos[i], found = info, true
+			}
+		}
+		if !found {
+			infos = append(infos, info)
+		}
+	}
+	for i := range infos {
+		if dm := s.drift[infos[i].Id]; dm != nil {
+			infos[i].Drift = dm.History()
+		}
+	}
+	return infos, nil
+}
+
+// openedSDR waits for a radio to finish opening, returning nil if it is
+// not open.
+func (s *Server) openedSDR(id string) radio.SDR {
+	s.rwmu.RLock()
+	sdr := s.sdrs[id]
+	var readyc <-chan struct{}
+	if sdr != nil {
+		readyc = sdr.readyc
+	}
+	s.rwmu.RUnlock()
+	if sdr == nil {
+		return nil
+	}
+	<-readyc
+	return sdr.SDR
+}
+
+// Gain is the current gain of a radio, or the default if it is not open.
+func (s *Server) Gain(id string) radio.GainConfig {
+	id = s.profiles.Resolve(id)
+	def := radio.DefaultGain
+	if g := s.profiles.Get(id).Gain; g != nil {
+		def = *g
+	}
+	sdr := s.openedSDR(id)
+	if sdr == nil {
+		return def
+	}
+	return sdr.Info().Gain
+}
+
+// Calibrate measures the frequency error of an open radio against the
+// reference, applying and recording the correction. Streams from the radio
+// are interrupted while it is tuned to the reference and resume after.
+func (s *Server) Calibrate(id string, ref radio.CalReference) (c radio.Calibration, err error) {
+	if s.cal == nil {
+		return radio.Calibration{}, radio.ErrUnsupported
+	}
+	err = s.retune(s.profiles.Resolve(id), func(sdr radio.SDR) (err error) {
+		c, err = s.cal.Calibrate(sdr, ref)
+		return err
+	})
+	return c, err
+}
+
+// retune runs f on an open radio that it may tune away from its band, as
+// long as f tunes it back. Signals and openers wait for f to finish.
+func (s *Server) retune(id string, f func(radio.SDR) error) error {
+	s.rwmu.Lock()
+	sdr := s.sdrs[id]
+	if sdr == nil {
+		s.rwmu.Unlock()
+		return sdrproxy.ErrRadioNotOpen
+	}
+	prevc, readyc := sdr.readyc, make(chan struct{})
+	sdr.readyc = readyc
+	sdr.retunes++
+	s.rwmu.Unlock()
+	defer close(readyc)
+
+	<-prevc
+	if sdr.SDR == nil {
+		return sdrproxy.ErrRadioNotOpen
+	}
+	return f(sdr.SDR)
+}
+
+// retunes is how many times the server has retuned the radio.
+func (s *Server) retunes(id string) int {
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+	if sdr := s.sdrs[id]; sdr != nil {
+		return sdr.retunes
+	}
+	return 0
+}
+
+// SetGain configures the gain of an open radio.
+func (s *Server) SetGain(id string, g radio.GainConfig) error {
+	id = s.profiles.Resolve(id)
+	sdr := s.openedSDR(id)
+	if sdr == nil {
+		return sdrproxy.ErrRadioNotOpen
+	}
+	return sdr.SetGain(g)
+}
+
 func (s *Server) Signals() (ret []sdrproxy.RxSignal) {
 	s.rwmu.RLock()
 	defer s.rwmu.RUnlock()
 	for _, sig := range s.signals {
-		rxsig := sdrproxy.RxSignal{Request: sig.req, Response: sig.resp}
+		rxsig := sdrproxy.RxSignal{Request: sig.req, Response: sig.resp, Stats: sig.Stats()}
 		ret = append(ret, rxsig)
 	}
 	return ret
@@ -3,6 +3,9 @@ package server
 import (
 	"context"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -52,3 +55,159 @@ func TestConcurrentOpen(t *testing.T) {
 		}
 	}
 }
+
+// beaconRadio is a simulated radio 10ppm off with a carrier at beaconRef.
+func beaconRadio(t *testing.T) string {
+	scenePath := filepath.Join(t.TempDir(), "scene.json")
+	scene := `{"noise_db": -50, "ppm": 10, "signals": [{"type": "carrier", "hz": 144390000, "db": -20}]}`
+	if err := os.WriteFile(scenePath, []byte(scene), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "sim:" + scenePath
+}
+
+var beaconRef = radio.CalReference{Type: radio.CalBeacon, Hz: 144390000}
+
+func TestPinnedPPM(t *testing.T) {
+	ser := beaconRadio(t)
+	ppm := int32(3)
+	tbl := radio.NewProfileTable()
+	tbl.Set(ser, radio.DeviceProfile{PPM: &ppm})
+
+	s := NewServer()
+	defer s.Close()
+	s.SetProfileTable(tbl)
+	s.SetDriftConfig(radio.DriftConfig{Reference: beaconRef})
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	sig, err := s.OpenSignal(ctx, sdrproxy.RxRequest{HzBand: testBand, Name: "pinned", Radio: ser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	if !s.driftMonitor(ser).Due() {
+		t.Fatal("measured drift of a pinned radio")
+	}
+	s.rwmu.RLock()
+	info := s.sdrs[ser].Info()
+	s.rwmu.RUnlock()
+	if info.PPM != ppm {
+		t.Fatalf("expected %dppm, got %d", ppm, info.PPM)
+	}
+}
+
+func TestIdleDrift(t *testing.T) {
+	ser := beaconRadio(t)
+	s := NewServer()
+	defer s.Close()
+	s.SetDriftConfig(radio.DriftConfig{Reference: beaconRef, Interval: time.Nanosecond})
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	sig, err := s.OpenSignal(ctx, sdrproxy.RxRequest{HzBand: testBand, Name: "idle", Radio: ser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sig.Chan()
+	dm := s.driftMonitor(ser)
+	if hist := dm.History(); len(hist) != 1 || hist[0].AppliedPPM != 10 {
+		t.Fatalf("expected 10ppm applied on open, got %+v", hist)
+	}
+
+	// Closing the last signal measures the idle radio before closing it.
+	sig.Close()
+	if hist := dm.History(); len(hist) != 2 {
+		t.Fatalf("expected drift measured on close, got %+v", hist)
+	}
+	s.rwmu.RLock()
+	defer s.rwmu.RUnlock()
+	if _, ok := s.sdrs[ser]; ok {
+		t.Fatal("idle radio left open")
+	}
+}
+
+func TestCalibrateSignal(t *testing.T) {
+	ser := beaconRadio(t)
+	cal, err := radio.LoadCalibrationTable(filepath.Join(t.TempDir(), "calibration.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	defer s.Close()
+	s.SetCalibrationTable(cal)
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	sig, err := s.OpenSignal(ctx, sdrproxy.RxRequest{HzBand: testBand, Name: "cal", Radio: ser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	<-sig.Chan()
+
+	c, err := s.Calibrate(ser, beaconRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PPM < 9 || c.PPM > 11 {
+		t.Fatalf("expected 10ppm, got %.2f", c.PPM)
+	}
+	// The signal picks up where the radio is retuned to its band.
+	for n := 0; n < int(testBand.Width); {
+		samps, ok := <-sig.Chan()
+		if !ok {
+			t.Fatalf("signal ended after calibrating: %v", sig.Err())
+		}
+		n += len(samps)
+	}
+	if st := sig.Stats(); st.Samples == 0 {
+		t.Fatalf("expected samples counted, got %+v", st)
+	}
+}
+
+// TestReplayChannel serves a channel off the center of a recording.
+func TestReplayChannel(t *testing.T) {
+	rec := radio.HzBand{Center: 100000000, Width: 240000}
+	path := filepath.Join(t.TempDir(), "100000000[240000].iq8")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A tone 100kHz above the recording's center.
+	samps := make([]complex64, rec.Width)
+	for i := range samps {
+		ph := 2 * math.Pi * 100000 * float64(i) / float64(rec.Width)
+		samps[i] = complex64(complex(0.5*math.Cos(ph), 0.5*math.Sin(ph)))
+	}
+	if err := radio.NewIQWriter(f).Write64(samps); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s := NewServer()
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+	band := radio.HzBand{Center: 100100000, Width: 24000}
+	sig, err := s.OpenSignal(ctx, sdrproxy.RxRequest{HzBand: band, Name: "replay", Radio: "file:" + path + "?loop=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sig.Close()
+	if got := sig.resp.Radio.HzBand(); got != rec {
+		t.Fatalf("expected radio tuned to %+v, got %+v", rec, got)
+	}
+	<-sig.Chan()
+	pwr, n := 0.0, 0
+	for n < int(band.Width)/10 {
+		samps, ok := <-sig.Chan()
+		if !ok {
+			t.Fatalf("channel closed: %v", sig.Err())
+		}
+		for _, v := range samps {
+			pwr += float64(real(v)*real(v) + imag(v)*imag(v))
+		}
+		n += len(samps)
+	}
+	if pwr /= float64(n); pwr < 0.1 {
+		t.Fatalf("expected tone in channel, got power %g", pwr)
+	}
+}
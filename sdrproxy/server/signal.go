@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"sync"
 
 	"github.com/chzchzchz/nicerx/dsp"
 	"github.com/chzchzchz/nicerx/radio"
@@ -18,9 +19,17 @@ type Signal struct {
 	sigc   <-chan []complex64
 	cancel context.CancelFunc
 	readyc <-chan struct{}
+
+	// iqr and stream are the radio reader and subscription being followed;
+	// prevStats counts the streams followed before.
+	iqr       *radio.MixerIQReader
+	stream    *radio.IQStream
+	prevStats radio.IQStats
+	mu        sync.Mutex
 }
 
-func newSignalChannel(ctx context.Context, req radio.HzBand, iqr *radio.MixerIQReader) (SignalChannel, error) {
+func (sig *Signal) newSignalChannel(ctx context.Context, rxreq sdrproxy.RxRequest, iqr *radio.MixerIQReader) (SignalChannel, error) {
+	req := rxreq.HzBand
 	if !req.Overlaps(iqr.HzBand) {
 		return nil, sdrproxy.ErrOutOfRange
 	}
@@ -44,11 +53,88 @@ func newSignalChannel(ctx context.Context, req radio.HzBand, iqr *radio.MixerIQR
 			return dsp.ResampleComplex64Ctx(ctx, resampleRatio, lpc)
 		}
 	}
-	return processSignal(iqr.BatchStream64(ctx, int(iqr.Width), 0)), nil
+	return processSignal(sig.follow(ctx, iqr, rxreq.StreamConfig(int(iqr.Width)))), nil
+}
+
+// follow streams samples from the radio's reader. When the server retunes
+// the radio, as to calibrate it, the reader is replaced; the signal moves
+// to the new reader if the radio is back on the same band.
+func (sig *Signal) follow(ctx context.Context, iqr *radio.MixerIQReader, cfg radio.StreamConfig) <-chan []complex64 {
+	sampc := make(chan []complex64)
+	go func() {
+		defer close(sampc)
+		for {
+			retunes := sig.serv.retunes(sig.req.Radio)
+			stream := iqr.SubscribeConfig(ctx, cfg)
+			sig.setStream(iqr, stream)
+			for samps := range stream.Samples(ctx) {
+				select {
+				case sampc <- samps:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			sdr := sig.serv.openedSDR(sig.req.Radio)
+			if sdr == nil || sig.serv.retunes(sig.req.Radio) == retunes {
+				return
+			}
+			if next := sdr.Reader(); next != iqr && next.HzBand == iqr.HzBand {
+				iqr = next
+				continue
+			}
+			return
+		}
+	}()
+	return sampc
+}
+
+func (s *Signal) setStream(iqr *radio.MixerIQReader, stream *radio.IQStream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream != nil {
+		st := s.stream.Stats()
+		s.prevStats.Samples += st.Samples
+		s.prevStats.Dropped += st.Dropped
+		s.prevStats.Overruns += st.Overruns
+	}
+	s.iqr, s.stream = iqr, stream
 }
 
 func (s *Signal) Response() sdrproxy.RxResponse { return s.resp }
 
+// Stats counts the radio samples delivered to and dropped by the signal.
+func (s *Signal) Stats() (st radio.IQStats) {
+	select {
+	case <-s.readyc:
+	default:
+		return st
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st = s.prevStats
+	if s.stream != nil {
+		cur := s.stream.Stats()
+		st.Samples += cur.Samples
+		st.Dropped += cur.Dropped
+		st.Overruns += cur.Overruns
+	}
+	return st
+}
+
+// Err is why the signal ended early, such as the radio disappearing. It is
+// only valid once the signal's channel has closed.
+func (s *Signal) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.iqr == nil {
+		return nil
+	}
+	return s.iqr.Err()
+}
+
 func (s *Signal) Chan() SignalChannel {
 	return s.sigc
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/chzchzchz/nicerx/radio"
+	"github.com/chzchzchz/nicerx/radio/sigmf"
 )
 
 type SignalStore struct {
@@ -23,14 +24,20 @@ func NewSignalStore(dir string) (*SignalStore, error) {
 	return &SignalStore{dir}, nil
 }
 
-func (ss *SignalStore) OpenFile(fb radio.FreqBand) (*os.File, error) {
+// OpenFile creates a cu8 SigMF recording of the band received by hw.
+func (ss *SignalStore) OpenFile(fb radio.FreqBand, hw radio.SDRHWInfo) (*os.File, error) {
 	fdir := filepath.Join(ss.baseDir, fmt.Sprintf("%.3f", fb.Center))
 	if err := os.MkdirAll(fdir, 0755); err != nil {
 		return nil, err
 	}
+	now := time.Now()
 	fn := filepath.Join(
 		fdir,
-		fmt.Sprintf("%d.%d.iq", time.Now().UnixNano(), int(fb.Width*1e6)))
+		fmt.Sprintf("%d.%d%s", now.UnixNano(), int(fb.Width*1e6), sigmf.DataExt))
+	meta := radio.NewSigMFMeta(radio.FormatCU8, fb.ToHzBand(), now, &hw)
+	if err := meta.WriteFile(fn); err != nil {
+		return nil, err
+	}
 	return os.OpenFile(fn, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
 }
 
@@ -99,7 +106,9 @@ type SignalFile struct {
 }
 
 func (ss *SignalStore) Signals(fb radio.FreqBand) (ret []SignalFile) {
-	return ss.findSignalSuffix(fb, ".iq")
+	ret = ss.findSignalSuffix(fb, sigmf.DataExt)
+	// Captures from before SigMF.
+	return append(ret, ss.findSignalSuffix(fb, ".iq")...)
 }
 
 func (ss *SignalStore) Spectrograms(fb radio.FreqBand) (ret []SignalFile) {